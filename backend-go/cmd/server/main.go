@@ -27,6 +27,12 @@ func main() {
 	r.POST("/dir", h.Dir)
 	r.GET("/read", h.Read)
 	r.POST("/save", h.Save)
+	r.GET("/sources", h.Sources)
+	r.POST("/storages", h.Storages)
+	r.POST("/test-connection", h.TestConnection)
+	r.GET("/control", h.Control)
+	r.POST("/control", h.Control)
+	r.GET("/log", h.Log)
 
 	// 通用分发路由 (通过 api-path 头或 _api 参数)
 	r.Any("/*path", h.Dispatch)
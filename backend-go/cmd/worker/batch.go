@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+const (
+	// 单个归档的文件数和大小上限
+	batchMaxFiles = 1000
+	batchMaxBytes = 64 << 20
+	// batchMaxPending 同时累积的目录数上限，超过时上传全部未满的批次
+	batchMaxPending = 32
+)
+
+// batcher 按目标目录累积小文件
+// 每批文件打包为一个归档，上传到所在目录后由服务端解压，因此归档内只有文件名、不含目录
+type batcher struct {
+	extractor service.Extractor
+	threshold int64
+	format    string
+
+	pending map[string]*fileBatch // 仅在调度协程中访问
+	seq     int
+
+	disabled atomic.Bool // 服务端解压失败后不再打包
+	warnOnce sync.Once
+}
+
+// fileBatch 同一目标目录下的一批小文件
+type fileBatch struct {
+	dir   string // 目标端相对目录，以 / 分隔
+	seq   int
+	files []service.ScanEntry
+	bytes int64
+}
+
+func newBatcher(extractor service.Extractor, threshold int64, format string) *batcher {
+	if format == "" {
+		format = service.ArchiveFormatZip
+	}
+	return &batcher{
+		extractor: extractor,
+		threshold: threshold,
+		format:    format,
+		pending:   make(map[string]*fileBatch),
+	}
+}
+
+// accepts 文件是否打包上传
+func (b *batcher) accepts(file service.ScanEntry) bool {
+	return file.Size < b.threshold && !b.disabled.Load()
+}
+
+// add 将文件加入所在目录的批次，返回需要立即上传的批次
+func (b *batcher) add(file service.ScanEntry) []*fileBatch {
+	var ready []*fileBatch
+	dir := path.Dir(toPosixPath(file.DstPath))
+	if dir == "." {
+		dir = ""
+	}
+
+	batch := b.pending[dir]
+	if batch == nil {
+		if len(b.pending) >= batchMaxPending {
+			ready = b.flushAll()
+		}
+		b.seq++
+		batch = &fileBatch{dir: dir, seq: b.seq}
+		b.pending[dir] = batch
+	}
+	batch.files = append(batch.files, file)
+	batch.bytes += file.Size
+
+	if len(batch.files) >= batchMaxFiles || batch.bytes >= batchMaxBytes {
+		delete(b.pending, dir)
+		ready = append(ready, batch)
+	}
+	return ready
+}
+
+// flushAll 取出全部未满的批次，按创建顺序返回
+func (b *batcher) flushAll() []*fileBatch {
+	batches := make([]*fileBatch, 0, len(b.pending))
+	for dir, batch := range b.pending {
+		batches = append(batches, batch)
+		delete(b.pending, dir)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].seq < batches[j].seq })
+	return batches
+}
+
+// uploadBatch 打包上传一批文件并在服务端解压
+// 解压后列出目标目录核对文件大小，缺失或不一致的文件逐个重新上传；服务端解压失败时改为逐个上传
+func (u *uploader) uploadBatch(batch *fileBatch) error {
+	files := make([]service.ScanEntry, 0, len(batch.files))
+	for _, file := range batch.files {
+		if !u.skipUnchanged(file) {
+			files = append(files, file)
+		}
+	}
+	if len(files) <= 1 || u.batch.disabled.Load() {
+		return u.uploadEach(files)
+	}
+
+	archiveRel := path.Join(batch.dir, fmt.Sprintf(".ftoz-batch-%d-%d.%s", os.Getpid(), batch.seq, u.batch.format))
+	u.report.update(func(s *model.TaskStatus) {
+		s.Status = "running"
+		s.Step = "upload"
+		s.Message = fmt.Sprintf("正在上传 %d/%d (打包 %d 个小文件)", s.TransferredFiles+1, s.TotalFiles, len(files))
+		s.CurrentFile = archiveRel
+	})
+
+	tmp, err := os.CreateTemp("", "ftoz-batch-*."+u.batch.format)
+	if err != nil {
+		return fmt.Errorf("创建临时归档失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	members := make([]service.ArchiveMember, 0, len(files))
+	for _, file := range files {
+		members = append(members, service.ArchiveMember{
+			Name:      path.Base(toPosixPath(file.DstPath)),
+			LocalPath: filepath.Join(u.sourceDir, file.Path),
+			Size:      file.Size,
+			ModTime:   file.ModTime,
+		})
+	}
+	err = service.WriteArchive(tmp, u.batch.format, members)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// 文件无法读取或已变化时逐个上传，由单文件上传处理
+		return u.uploadEach(files)
+	}
+
+	if err := u.putFile(archiveRel, tmp.Name()); err != nil {
+		return err
+	}
+	extractErr := u.batch.extractor.Extract(archiveRel, batch.dir)
+
+	var verified []service.ScanEntry
+	var retry []service.ScanEntry
+	if extractErr == nil {
+		listing := make(map[string]service.DestEntry)
+		if entries, err := u.dest.List(batch.dir); err == nil {
+			for _, e := range entries {
+				listing[e.Name] = e
+			}
+		}
+		for _, file := range files {
+			e, ok := listing[path.Base(toPosixPath(file.DstPath))]
+			if ok && !e.IsDir && e.Size == file.Size {
+				verified = append(verified, file)
+			} else {
+				retry = append(retry, file)
+			}
+		}
+		// 打包后被修改的文件逐个重新上传，由单文件上传检查变化
+		if u.changes != nil {
+			kept := verified[:0]
+			for _, file := range verified {
+				if u.changedSinceScan(file) {
+					retry = append(retry, file)
+					continue
+				}
+				u.changes.record(file)
+				kept = append(kept, file)
+			}
+			verified = kept
+		}
+	} else {
+		u.batch.disabled.Store(true)
+		u.batch.warnOnce.Do(func() {
+			u.report.warn("服务端解压失败，改为逐个上传: " + extractErr.Error())
+		})
+		retry = files
+	}
+
+	if err := u.batch.extractor.Remove(archiveRel); err != nil {
+		u.report.warn(fmt.Sprintf("删除临时归档 %s 失败: %v", archiveRel, err))
+	}
+
+	var bytes int64
+	for _, file := range verified {
+		bytes += file.Size
+	}
+	if u.space != nil {
+		u.space.Consume(bytes)
+	}
+	u.report.update(func(s *model.TaskStatus) {
+		s.TransferredFiles += len(verified)
+		s.TransferredBytes += bytes
+		s.Message = fmt.Sprintf("正在上传 %d/%d", s.TransferredFiles, s.TotalFiles)
+	})
+	return u.uploadEach(retry)
+}
+
+// uploadEach 逐个上传文件
+func (u *uploader) uploadEach(files []service.ScanEntry) error {
+	for _, file := range files {
+		if err := u.uploadFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+const (
+	// changeRetries 文件在上传过程中被修改时重新上传的次数，仍在变化时标记为 unstable
+	changeRetries = 2
+	// maxChangedPaths 结果中保留的变化文件数
+	maxChangedPaths = 1000
+)
+
+// changeTracker 记录迁移过程中发生变化的文件
+// 每个文件上传前后的检查总是进行；最终核对 (reconcile) 需要的扫描条目和上传时的状态仅在启用核对时记录
+type changeTracker struct {
+	policy string
+
+	mu       sync.Mutex
+	seen     map[string]bool              // 首次扫描到的源路径，包括路径校验跳过的
+	uploaded map[string]service.ScanEntry // 源相对路径 -> 上传时的条目
+	links    map[string]string            // 硬链接文件的 inode -> 首次扫描输出的源路径
+	changed  []model.ChangedPath
+	flagged  map[string]int // 变化类型 + 路径 -> changed 中的下标，超出保留条数时为 -1
+	count    int
+	kinds    map[string]int // 各变化类型的文件数
+
+	// 文件新增、删除或大小变化导致的总数调整
+	filesDelta int
+	bytesDelta int64
+}
+
+func newChangeTracker(policy string) *changeTracker {
+	return &changeTracker{
+		policy:   policy,
+		seen:     make(map[string]bool),
+		uploaded: make(map[string]service.ScanEntry),
+		links:    make(map[string]string),
+		flagged:  make(map[string]int),
+		kinds:    make(map[string]int),
+	}
+}
+
+// reconciles 是否在全部上传后重新扫描源目录核对
+func (t *changeTracker) reconciles() bool {
+	return t.policy == service.ChangePolicyReupload || t.policy == service.ChangePolicyWarn
+}
+
+// see 记录首次扫描到的条目，用于核对新增的文件
+func (t *changeTracker) see(entries ...service.ScanEntry) {
+	if !t.reconciles() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, entry := range entries {
+		t.seen[entry.Path] = true
+		if entry.HardLink != "" {
+			t.links[entry.HardLink] = entry.Path
+		}
+	}
+}
+
+// record 记录文件上传 (或确认目标端已是最新) 时的状态，用于核对上传后被修改和删除的文件
+func (t *changeTracker) record(file service.ScanEntry) {
+	if !t.reconciles() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seen[file.Path] = true
+	t.uploaded[file.Path] = file
+}
+
+// flag 记录变化的文件，同一文件的同类变化只记录一次，以最后一次的上传结果为准
+func (t *changeTracker) flag(file service.ScanEntry, change string, uploaded bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := change + "\x00" + file.Path
+	if i, ok := t.flagged[key]; ok {
+		if i >= 0 {
+			t.changed[i].Uploaded = uploaded
+		}
+		return
+	}
+
+	t.count++
+	t.kinds[change]++
+	t.flagged[key] = -1
+	if len(t.changed) < maxChangedPaths {
+		t.flagged[key] = len(t.changed)
+		t.changed = append(t.changed, model.ChangedPath{
+			Path:     filepath.ToSlash(file.Path),
+			DstPath:  toPosixPath(file.DstPath),
+			Change:   change,
+			Uploaded: uploaded,
+		})
+	}
+}
+
+// isUnstable 文件是否已标记为上传时仍在被写入
+func (t *changeTracker) isUnstable(file service.ScanEntry) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.flagged[service.ChangeUnstable+"\x00"+file.Path]
+	return ok
+}
+
+// reupload 是否重新上传变化的文件，warn 模式下只记录
+func (t *changeTracker) reupload() bool {
+	return t.policy != service.ChangePolicyWarn
+}
+
+// resize 调整文件总数和总大小，同步到任务状态
+func (u *uploader) resize(files int, bytes int64) {
+	u.changes.mu.Lock()
+	u.changes.filesDelta += files
+	u.changes.bytesDelta += bytes
+	u.changes.mu.Unlock()
+	u.report.update(func(s *model.TaskStatus) {
+		s.TotalFiles += files
+		s.TotalBytes += bytes
+	})
+}
+
+// sendFile 上传文件，检查文件在扫描后和上传过程中是否变化
+// 上传前已变化时上传当前内容；上传过程中变化时重新上传，多次仍在变化则标记为 unstable。
+// file 更新为实际上传的大小和修改时间；文件已被删除时返回 false
+func (u *uploader) sendFile(file *service.ScanEntry) (bool, error) {
+	relPosix := toPosixPath(file.DstPath)
+	fullPath := filepath.Join(u.sourceDir, file.Path)
+	if u.changes == nil {
+		return true, u.putFile(relPosix, fullPath)
+	}
+
+	before, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		u.removed(*file)
+		return false, nil
+	}
+	if err == nil && service.FileChanged(*file, before) {
+		u.report.logf("%s 扫描后已被修改，上传当前内容", relPosix)
+		u.changes.flag(*file, service.ChangeModified, true)
+		u.resize(0, before.Size()-file.Size)
+		file.Size, file.ModTime = before.Size(), before.ModTime()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := u.putFile(relPosix, fullPath); err != nil {
+			if _, statErr := os.Stat(fullPath); os.IsNotExist(statErr) {
+				u.removed(*file)
+				return false, nil
+			}
+			return false, err
+		}
+
+		after, err := os.Stat(fullPath)
+		if err != nil || !service.FileChanged(*file, after) {
+			break
+		}
+		if !u.changes.reupload() || attempt >= changeRetries {
+			u.report.logf("%s 上传过程中被修改，目标端文件可能不完整", relPosix)
+			u.changes.flag(*file, service.ChangeUnstable, false)
+			break
+		}
+		u.report.logf("%s 上传过程中被修改，重新上传 (%d/%d)", relPosix, attempt+1, changeRetries)
+		u.resize(0, after.Size()-file.Size)
+		file.Size, file.ModTime = after.Size(), after.ModTime()
+	}
+	u.changes.record(*file)
+	return true, nil
+}
+
+// removed 记录上传前被删除的文件，从总数中扣除
+func (u *uploader) removed(file service.ScanEntry) {
+	u.report.logf("%s 扫描后已被删除，未上传", toPosixPath(file.DstPath))
+	u.changes.flag(file, service.ChangeRemoved, false)
+	u.resize(-1, -file.Size)
+}
+
+// changedSinceScan 文件当前状态与扫描时不同 (包括已被删除)
+func (u *uploader) changedSinceScan(file service.ScanEntry) bool {
+	info, err := os.Stat(filepath.Join(u.sourceDir, file.Path))
+	return err != nil || service.FileChanged(file, info)
+}
+
+// reconcile 全部上传后重新扫描源目录，核对迁移期间新增、修改和删除的文件
+// reupload 模式下上传新增文件、重新上传上传后被修改的文件；删除的文件只记录，目标端保留
+func (u *uploader) reconcile(scanner *service.Scanner, check *service.PathValidator) error {
+	u.report.running("upload", "正在核对迁移期间的源文件变化...")
+	result, err := scanner.Scan(u.sourceDir)
+	if err != nil {
+		u.report.warn("核对源文件变化失败: " + err.Error())
+		return nil
+	}
+
+	t := u.changes
+	current := make(map[string]bool, len(result.Files))
+	var added, modified []service.ScanEntry
+	for _, dir := range result.Dirs {
+		if t.seen[dir.Path] || !checkNew(check, &dir) {
+			continue
+		}
+		if t.reupload() {
+			if err := u.createDir(dir.DstPath); err != nil {
+				return err
+			}
+			if u.dirTimer != nil {
+				u.dirs = append(u.dirs, dir)
+			}
+		}
+	}
+	for _, file := range result.Files {
+		// 硬链接按 inode 对应首次扫描输出的路径，重新扫描输出同一 inode 的其他路径时不视为新增和删除
+		if p, ok := t.links[file.HardLink]; ok && p != file.Path {
+			if _, err := os.Lstat(filepath.Join(u.sourceDir, p)); err == nil {
+				file.Path = p
+			}
+		}
+		current[file.Path] = true
+		prev, ok := t.uploaded[file.Path]
+		switch {
+		case !t.seen[file.Path]:
+			if checkNew(check, &file) {
+				added = append(added, file)
+			}
+		case ok && (prev.Size != file.Size || !prev.ModTime.Equal(file.ModTime)) && !t.isUnstable(file):
+			// 上传时仍在被写入的文件已多次重试，不再重新上传；目标路径沿用首次上传时 (经过路径校验) 的路径
+			file.DstPath = prev.DstPath
+			modified = append(modified, file)
+		}
+	}
+
+	var removed []service.ScanEntry
+	for p, file := range t.uploaded {
+		if !current[p] {
+			removed = append(removed, file)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Path < removed[j].Path })
+	for _, file := range removed {
+		u.report.logf("%s 上传后已被删除，目标端保留该文件", toPosixPath(file.DstPath))
+		t.flag(file, service.ChangeRemoved, true)
+	}
+
+	for _, file := range modified {
+		prev := t.uploaded[file.Path]
+		if !t.reupload() {
+			u.report.logf("%s 上传后被修改，未重新上传", toPosixPath(file.DstPath))
+			t.flag(file, service.ChangeModified, false)
+			continue
+		}
+		u.report.logf("%s 上传后被修改，重新上传", toPosixPath(file.DstPath))
+		u.report.update(func(s *model.TaskStatus) {
+			s.CurrentFile = toPosixPath(file.DstPath)
+		})
+		// 总大小先按重新扫描时的大小调整，上传过程中的变化由 sendFile 调整
+		u.resize(0, file.Size-prev.Size)
+		ok, err := u.sendFile(&file)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// 已从总数中扣除，已传输数同样扣除首次上传的内容
+			u.report.update(func(s *model.TaskStatus) {
+				s.TransferredFiles--
+				s.TransferredBytes -= prev.Size
+			})
+			continue
+		}
+		t.flag(file, service.ChangeModified, true)
+		u.report.update(func(s *model.TaskStatus) {
+			s.TransferredBytes += file.Size - prev.Size
+		})
+	}
+
+	for _, file := range added {
+		if !t.reupload() {
+			u.report.logf("%s 扫描后新增，未上传", toPosixPath(file.DstPath))
+			t.flag(file, service.ChangeAdded, false)
+			continue
+		}
+		u.report.logf("%s 扫描后新增，上传", toPosixPath(file.DstPath))
+		u.resize(1, file.Size)
+		if err := u.uploadFile(file); err != nil {
+			return err
+		}
+		if _, ok := t.uploaded[file.Path]; ok {
+			t.flag(file, service.ChangeAdded, true)
+		}
+	}
+	return nil
+}
+
+// checkNew 按目标端路径规则校验核对时新增的条目，不符合规则 (包括 report 模式下的违规) 时跳过
+func checkNew(check *service.PathValidator, entry *service.ScanEntry) bool {
+	if check == nil {
+		return true
+	}
+	n := check.ViolationCount
+	return check.Check(entry) && check.ViolationCount == n
+}
+
+// warnings 汇总变化的文件数，用于任务警告
+func (t *changeTracker) warnings() []string {
+	var warnings []string
+	if n := t.kinds[service.ChangeModified]; n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d 个文件在迁移过程中被修改", n))
+	}
+	if n := t.kinds[service.ChangeUnstable]; n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d 个文件上传时仍在被写入，目标端文件可能不完整", n))
+	}
+	if n := t.kinds[service.ChangeAdded]; n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d 个文件在扫描后新增", n))
+	}
+	if n := t.kinds[service.ChangeRemoved]; n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d 个文件在迁移过程中被删除", n))
+	}
+	return warnings
+}
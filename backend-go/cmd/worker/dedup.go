@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"ftoz/internal/model"
+)
+
+// copyDuplicates 在原件全部上传后由目标端复制重复文件，复制失败时改为直接上传
+func (u *uploader) copyDuplicates() error {
+	total := 0
+	for _, set := range u.duplicates {
+		total += len(set.Copies)
+	}
+
+	done := 0
+	for _, set := range u.duplicates {
+		original := toPosixPath(set.Original.DstPath)
+		for _, file := range set.Copies {
+			done++
+			relPosix := toPosixPath(file.DstPath)
+			u.report.update(func(s *model.TaskStatus) {
+				s.Status = "running"
+				s.Step = "upload"
+				s.Message = fmt.Sprintf("正在复制重复文件 %d/%d", done, total)
+				s.CurrentFile = relPosix
+			})
+
+			if u.skipUnchanged(file) {
+				continue
+			}
+			if u.throttle != nil {
+				u.throttle.waitWindow(context.Background())
+			}
+			// 原件或副本在查找重复文件后被修改时内容可能已不同，直接上传
+			if u.changes != nil && (u.changedSinceScan(set.Original) || u.changedSinceScan(file)) {
+				if err := u.uploadFile(file); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := u.copier.Copy(original, relPosix); err != nil {
+				u.copyFailed++
+				if err := u.uploadFile(file); err != nil {
+					return err
+				}
+				continue
+			}
+			if u.changes != nil {
+				u.changes.record(file)
+			}
+
+			u.savedBytes += file.Size
+			if u.space != nil {
+				u.space.Consume(file.Size)
+			}
+			u.report.update(func(s *model.TaskStatus) {
+				s.TransferredFiles++
+				s.TransferredBytes += file.Size
+			})
+		}
+	}
+	return nil
+}
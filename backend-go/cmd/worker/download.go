@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+// runDownload 反向迁移：将 ZimaOS 目录下载到本地 FNOS 目录，保留目录结构和修改时间
+func runDownload(client *service.ZimaOSClient, req *model.MigrateRequest, token, remoteRoot, localDir string, report *reporter) {
+	// CasaOS 没有目录信息接口，通过列目录确认源目录存在
+	if _, err := client.ListFiles(req.BaseURL, token, remoteRoot); err != nil {
+		report.fail("login", err)
+		return
+	}
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		report.fail("login", fmt.Errorf("创建本地目录失败: %w", err))
+		return
+	}
+
+	// 1. 读取远程目录
+	report.running("scan", "正在读取 ZimaOS 目录...")
+
+	var lastReport time.Time
+	scanResult, err := client.ScanRemote(req.BaseURL, token, remoteRoot, func(p model.ScanProgress) {
+		if time.Since(lastReport) < time.Second {
+			return
+		}
+		lastReport = time.Now()
+		report.update(func(s *model.TaskStatus) {
+			s.Scan = &p
+			s.Message = fmt.Sprintf("正在读取 ZimaOS 目录：已发现 %d 个文件", p.FilesFound)
+		})
+	})
+	if err != nil {
+		report.fail("scan", err)
+		return
+	}
+
+	totalFiles := len(scanResult.Files)
+	totalBytes := scanResult.TotalBytes
+	report.update(func(s *model.TaskStatus) {
+		s.Step = "scan"
+		s.Message = fmt.Sprintf("读取完成：%d 个文件，共 %s", totalFiles, service.FormatBytes(totalBytes))
+		s.TotalFiles = totalFiles
+		s.TotalBytes = totalBytes
+		s.Scan = &model.ScanProgress{
+			DirsVisited: int64(len(scanResult.Dirs) + 1),
+			DirsFound:   int64(len(scanResult.Dirs)),
+			FilesFound:  int64(totalFiles),
+			BytesFound:  totalBytes,
+			Skipped:     int64(len(scanResult.Skipped)),
+			Done:        true,
+		}
+	})
+
+	// 2. 容量检查：比较远程目录大小与本地可用空间
+	d := newDownloader(client, req.BaseURL, token, localDir, req.Concurrency, report)
+	if req.CapacityCheck != service.CapacityCheckOff {
+		d.space = service.NewSpaceWatcher(func() (int64, error) {
+			return service.LocalFree(localDir)
+		}, req.ReserveBytes)
+		if err := d.space.CheckTotal(totalBytes); err != nil {
+			if !errors.Is(err, service.ErrInsufficientSpace) || req.CapacityCheck == service.CapacityCheckWarn {
+				report.warn(err.Error())
+			} else {
+				report.fail("scan", err)
+				return
+			}
+		}
+	}
+
+	// 3. 下载
+	startMsg := "开始下载文件..."
+	if totalFiles == 0 {
+		startMsg = "无需下载文件"
+	}
+	report.running("download", startMsg)
+
+	for _, dir := range scanResult.Dirs {
+		if err := os.MkdirAll(filepath.Join(localDir, dir.RelPath), 0755); err != nil {
+			report.fail("download", fmt.Errorf("创建本地目录失败: %w", err))
+			return
+		}
+	}
+	if err := d.run(scanResult.Files); err != nil {
+		report.fail("download", err)
+		return
+	}
+
+	// 文件写入会改变目录修改时间，最后由深到浅恢复目录时间
+	dirs := scanResult.Dirs
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i].RelPath, string(filepath.Separator)) > strings.Count(dirs[j].RelPath, string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		if dir.Modified > 0 {
+			mtime := dir.ModTime()
+			os.Chtimes(filepath.Join(localDir, dir.RelPath), mtime, mtime)
+		}
+	}
+
+	// 4. 完成
+	result := model.MigrateResult{
+		DstPath:      localDir,
+		SourceDir:    remoteRoot,
+		SourceType:   "zimaos",
+		TotalFiles:   totalFiles,
+		TotalBytes:   totalBytes,
+		Skipped:      scanResult.Skipped,
+		SkippedCount: len(scanResult.Skipped),
+	}
+	if len(scanResult.Skipped) > 0 {
+		report.warn(fmt.Sprintf("跳过 %d 个文件名无法在本地使用的路径", len(scanResult.Skipped)))
+	}
+	if skipped := d.unchanged; skipped > 0 {
+		report.warn(fmt.Sprintf("%d 个本地已存在且大小、修改时间相同的文件未重新下载", skipped))
+	}
+	report.logf("下载完成: %d 个文件", result.TotalFiles)
+	report.update(func(s *model.TaskStatus) {
+		s.Status = "success"
+		s.Step = "done"
+		s.Message = "下载完成"
+		s.CurrentFile = ""
+		s.Result = &result
+	})
+}
+
+// downloader 并发下载远程文件到本地目录
+type downloader struct {
+	client      *service.ZimaOSClient
+	baseURL     string
+	token       string
+	localDir    string
+	concurrency int
+	report      *reporter
+	space       *service.SpaceWatcher
+
+	mu        sync.Mutex
+	unchanged int // 本地已是最新而跳过的文件数
+}
+
+func newDownloader(client *service.ZimaOSClient, baseURL, token, localDir string, concurrency int, report *reporter) *downloader {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &downloader{
+		client:      client,
+		baseURL:     baseURL,
+		token:       token,
+		localDir:    localDir,
+		concurrency: concurrency,
+		report:      report,
+	}
+}
+
+// run 下载全部文件，任一文件失败时停止并返回错误
+func (d *downloader) run(files []service.RemoteEntry) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobs := make(chan service.RemoteEntry)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for i := 0; i < d.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				if err := d.downloadFile(file); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, file := range files {
+		// 下载前确认本地存储空间充足，不足时暂停等待
+		if d.space != nil {
+			err := d.space.Wait(file.Size, func(free int64) {
+				d.report.update(func(s *model.TaskStatus) {
+					s.Status = "paused"
+					s.Message = fmt.Sprintf("本地存储可用空间不足 (剩余 %s)，已暂停，等待释放空间...", service.FormatBytes(free))
+				})
+			})
+			if err != nil {
+				d.report.warn(err.Error())
+			}
+		}
+
+		select {
+		case jobs <- file:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// downloadFile 下载单个文件：先写入同目录的临时文件，完成后设置修改时间并改名
+func (d *downloader) downloadFile(file service.RemoteEntry) error {
+	relPosix := toPosixPath(file.RelPath)
+	localPath := filepath.Join(d.localDir, file.RelPath)
+	mtime := file.ModTime()
+
+	d.report.update(func(s *model.TaskStatus) {
+		s.Status = "running"
+		s.Step = "download"
+		s.Message = fmt.Sprintf("正在下载 %d/%d", s.TransferredFiles+1, s.TotalFiles)
+		s.CurrentFile = relPosix
+	})
+
+	// 本地已存在相同大小和修改时间的文件时跳过
+	if info, err := os.Stat(localPath); err == nil && info.Mode().IsRegular() &&
+		info.Size() == file.Size && file.Modified > 0 && info.ModTime().Unix() == mtime.Unix() {
+		d.mu.Lock()
+		d.unchanged++
+		d.mu.Unlock()
+		d.done(file.Size)
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), ".ftoz-*.part")
+	if err != nil {
+		return fmt.Errorf("创建本地文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := d.client.DownloadFile(d.baseURL, d.token, file.Path, tmp)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("写入本地文件失败: %w", closeErr)
+	}
+	if err == nil && n != file.Size {
+		err = fmt.Errorf("文件大小不一致: 预期 %d 字节，实际 %d 字节", file.Size, n)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", relPosix, err)
+	}
+
+	os.Chmod(tmp.Name(), 0644)
+	if file.Modified > 0 {
+		os.Chtimes(tmp.Name(), mtime, mtime)
+	}
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		return fmt.Errorf("保存本地文件失败: %w", err)
+	}
+
+	if d.space != nil {
+		d.space.Consume(file.Size)
+	}
+	d.done(file.Size)
+	return nil
+}
+
+func (d *downloader) done(size int64) {
+	d.report.update(func(s *model.TaskStatus) {
+		s.TransferredFiles++
+		s.TransferredBytes += size
+		s.Message = fmt.Sprintf("正在下载 %d/%d", s.TransferredFiles, s.TotalFiles)
+	})
+}
@@ -1,27 +1,27 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
+	"ftoz/internal/config"
 	"ftoz/internal/model"
 	"ftoz/internal/service"
 )
 
 const (
-	StatusDir        = "/tmp"
-	DefaultSourceDir = "/vol1/1000"
-)
+	WorkerPath = "/var/apps/ftoz/target/server/worker"
 
-var SourceMap = map[string]model.SourceInfo{
-	"personal": {Dir: "/vol1/1000", Label: "personal"},
-	"team":     {Dir: "/vol1/@team", Label: "team"},
-}
+	// maxDirStats 结果和任务日志中保留的一级子目录统计数
+	maxDirStats = 100
+)
 
 func main() {
 	if len(os.Args) < 3 {
@@ -41,285 +41,710 @@ func main() {
 			Error:      "参数解析失败: " + err.Error(),
 			UpdateTime: time.Now().Unix(),
 		})
+		finishTask(taskId)
 		os.Exit(1)
 	}
 
 	// 执行迁移
 	runMigration(taskId, &req)
+	finishTask(taskId)
+}
+
+// finishTask 将任务移出队列并删除控制文件，然后启动排队中的下一个任务
+func finishTask(taskId string) {
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{}
+	}
+
+	workerPath, err := os.Executable()
+	if err != nil {
+		workerPath = WorkerPath
+	}
+
+	if err := service.NewTaskQueue(workerPath, cfg.Queue.MaxRunning).Finish(taskId); err != nil {
+		fmt.Fprintln(os.Stderr, "更新任务队列失败:", err)
+	}
+	service.RemoveTaskControl(taskId)
 }
 
 func runMigration(taskId string, req *model.MigrateRequest) {
-	zimaClient := service.NewZimaOSClient()
-	scanner := service.NewScanner()
+	scanner := service.NewScanner(service.ScanOptions{
+		Workers:       req.ScanWorkers,
+		Strict:        req.StrictScan,
+		Links:         req.Links,
+		OneFileSystem: req.OneFileSystem,
+		NameCharset:   req.NameCharset,
+		Normalize:     req.Normalize,
+		OnCollision:   req.OnCollision,
+	})
+	report := newReporter(taskId)
 
 	// 验证参数
 	req.BaseURL = strings.TrimRight(strings.TrimSpace(req.BaseURL), "/")
 	req.Username = strings.TrimSpace(req.Username)
 	req.Storage = strings.Trim(strings.TrimSpace(req.Storage), "/")
 
-	if req.BaseURL == "" || req.Username == "" || req.Password == "" {
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:     taskId,
-			Status:     "error",
-			Error:      "缺少 baseUrl/username/password",
-			UpdateTime: time.Now().Unix(),
-		})
+	cfg, err := config.Load()
+	if err != nil {
+		report.failMsg("", "读取配置失败: "+err.Error())
+		return
+	}
+
+	// 连接 ZimaOS 的传输层，所有请求共用连接池
+	transport, err := service.NewHTTPTransport(service.MergeTransportOptions(cfg.Transport, req.Transport), req.Concurrency)
+	if err != nil {
+		report.fail("", err)
+		return
+	}
+
+	dest, dstRoot, err := newDestination(req, transport)
+	if err != nil {
+		report.fail("", err)
 		return
 	}
 
 	// 解析源目录
-	sourceInfo := resolveSource(req.Source, req.Space)
+	sourceInfo := service.NewSourceRegistry(cfg).Resolve(req.Source, req.Space)
 	if sourceInfo == nil {
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:     taskId,
-			Status:     "error",
-			Error:      "未知的迁移空间",
-			UpdateTime: time.Now().Unix(),
-		})
+		report.failMsg("", "未知的迁移空间")
 		return
 	}
 
 	// 验证源目录存在
 	stat, err := os.Stat(sourceInfo.Dir)
 	if os.IsNotExist(err) {
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:     taskId,
-			Status:     "error",
-			Error:      "源目录不存在",
-			UpdateTime: time.Now().Unix(),
-		})
+		report.failMsg("", "源目录不存在")
 		return
 	}
 	if !stat.IsDir() {
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:     taskId,
-			Status:     "error",
-			Error:      "源路径不是目录",
-			UpdateTime: time.Now().Unix(),
-		})
+		report.failMsg("", "源路径不是目录")
 		return
 	}
 
-	storagePath := "/media"
-	if req.Storage != "" {
-		storagePath = "/media/" + strings.Trim(req.Storage, "/")
+	// 反向迁移：从 ZimaOS 下载到本地
+	if req.Direction == service.DirectionDownload {
+		report.running("login", "正在登录 ZimaOS...")
+		zimaClient := service.NewZimaOSClient()
+		zimaClient.SetTransport(transport)
+		zimaClient.SetAPIProfile(req.APIProfile)
+		token, err := zimaClient.Login(req.BaseURL, req.Username, req.Password)
+		if err != nil {
+			report.fail("login", err)
+			return
+		}
+		remoteRoot := dstRoot
+		if req.RemotePath != "" {
+			remoteRoot = "/" + strings.Trim(req.RemotePath, "/")
+		}
+		runDownload(zimaClient, req, token, remoteRoot, filepath.Join(sourceInfo.Dir, req.LocalPath), report)
+		return
 	}
 
-	// 1. 登录
-	updateStatus(taskId, &model.TaskStatus{
-		TaskID:     taskId,
-		Status:     "running",
-		Step:       "login",
-		Message:    "正在登录 ZimaOS...",
-		UpdateTime: time.Now().Unix(),
-	})
+	// 拉取模式：由 ZimaOS 从本机临时共享中导入
+	if req.Pull {
+		report.running("login", "正在登录 ZimaOS...")
+		zimaClient := service.NewZimaOSClient()
+		zimaClient.SetTransport(transport)
+		zimaClient.SetAPIProfile(req.APIProfile)
+		token, err := zimaClient.Login(req.BaseURL, req.Username, req.Password)
+		if err == nil {
+			err = zimaClient.ValidateStorage(req.BaseURL, token, req.Storage)
+		}
+		if err != nil {
+			report.fail("login", err)
+			return
+		}
+		runPull(zimaClient, req, token, dstRoot, sourceInfo, report)
+		return
+	}
 
-	token, err := zimaClient.Login(req.BaseURL, req.Username, req.Password)
-	if err != nil {
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:     taskId,
-			Status:     "error",
-			Step:       "login",
-			Error:      err.Error(),
-			UpdateTime: time.Now().Unix(),
-		})
+	// 1. 连接目标端
+	switch req.DestType {
+	case service.DestTypeLocal:
+		report.running("login", "正在检查目标目录...")
+	case service.DestTypeWebDAV:
+		report.running("login", "正在连接 WebDAV...")
+	case service.DestTypeS3:
+		report.running("login", "正在连接对象存储...")
+	case service.DestTypeSFTP:
+		report.running("login", "正在连接 SFTP...")
+	default:
+		report.running("login", "正在登录 ZimaOS...")
+	}
+	if err := dest.Authenticate(); err != nil {
+		report.fail("login", err)
 		return
 	}
+	report.running("login", "登录成功")
 
-	updateStatus(taskId, &model.TaskStatus{
-		TaskID:     taskId,
-		Status:     "running",
-		Step:       "login",
-		Message:    "登录成功",
-		UpdateTime: time.Now().Unix(),
-	})
+	switch {
+	case req.StallTimeout == service.StallTimeoutDisabled:
+		service.SetStallTimeout(0)
+	case req.StallTimeout > 0:
+		service.SetStallTimeout(time.Duration(req.StallTimeout) * time.Second)
+	}
 
-	// 2. 扫描目录
-	updateStatus(taskId, &model.TaskStatus{
-		TaskID:     taskId,
-		Status:     "running",
-		Step:       "scan",
-		Message:    "正在扫描目录...",
-		UpdateTime: time.Now().Unix(),
-	})
+	up := newUploader(dest, sourceInfo.Dir, req.Concurrency, report)
+	up.sync = req.Sync
+	if dt, ok := dest.(service.DirTimer); ok {
+		up.dirTimer = dt
+	}
+	if req.Metadata {
+		up.metadata = []service.ScanEntry{}
+	}
+	// 上传前后检查文件变化，onChange 决定是否在最后重新扫描核对
+	up.changes = newChangeTracker(req.OnChange)
+
+	// 上传限速和传输时段，运行中可通过控制文件调整
+	up.throttle = newThrottle(taskId, req, report)
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go up.throttle.watch(watchCtx)
+
+	// 小文件打包上传，由目标端解压
+	if req.BatchThreshold > 0 {
+		if ex, ok := dest.(service.Extractor); ok {
+			up.batch = newBatcher(ex, req.BatchThreshold, req.BatchFormat)
+		} else {
+			report.warn("目标端不支持服务端解压，小文件逐个上传")
+		}
+	}
+
+	// 内容去重，重复文件由目标端复制
+	if req.Dedup {
+		if c, ok := dest.(service.Copier); ok {
+			up.copier = c
+			defer func() {
+				if err := c.CleanupCopies(); err != nil {
+					report.logf("删除复制使用的临时目录失败: %v", err)
+				}
+			}()
+		} else {
+			report.warn("目标端不支持服务端复制，不进行去重")
+		}
+	}
+
+	// 目标存储空间监控
+	var spaceWatcher *service.SpaceWatcher
+	if fs, ok := dest.(service.FreeSpacer); ok && req.CapacityCheck != service.CapacityCheckOff {
+		spaceWatcher = service.NewSpaceWatcher(fs.Free, req.ReserveBytes)
+		up.space = spaceWatcher
+	}
+
+	// 目标端路径规则校验
+	var check *service.PathValidator
+	if req.PathCheck != service.PathCheckOff {
+		check = service.NewPathValidator(cfg.Paths, req.PathCheck, dstRoot)
+	}
+
+	// 2. 扫描目录 + 3. 上传文件
+	report.running("scan", "正在扫描目录...")
 
-	files, dirs, err := scanner.Scan(sourceInfo.Dir)
+	var summary *service.ScanSummary
+	stream := req.Stream
+	if stream && req.PathCheck == service.PathCheckReport {
+		// report 模式须在上传开始前列出全部不符合规则的路径
+		report.warn("pathCheck 为 report 时需在上传前完成路径校验，已改为先扫描后上传")
+		stream = false
+	}
+	if stream {
+		summary, err = streamMigration(scanner, up, sourceInfo.Dir, check, report)
+	} else {
+		summary, err = scanThenUpload(scanner, up, sourceInfo.Dir, req.CapacityCheck, spaceWatcher, check, report)
+	}
 	if err != nil {
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:     taskId,
-			Status:     "error",
-			Step:       "scan",
-			Error:      err.Error(),
-			UpdateTime: time.Now().Unix(),
-		})
+		if errors.Is(err, service.ErrInvalidPaths) {
+			// 列出不符合规则的路径
+			report.update(func(s *model.TaskStatus) {
+				s.Result = &model.MigrateResult{
+					DstPath:        dstRoot,
+					SourceDir:      sourceInfo.Dir,
+					SourceType:     sourceInfo.Type,
+					PathViolations: check.Violations,
+				}
+			})
+		}
+		if report.snapshot().Status != "error" {
+			report.fail("", err)
+		}
 		return
 	}
 
-	totalFiles := len(files)
-	updateStatus(taskId, &model.TaskStatus{
-		TaskID:     taskId,
-		Status:     "running",
-		Step:       "scan",
-		Message:    fmt.Sprintf("扫描完成：%d 个文件", totalFiles),
-		TotalFiles: totalFiles,
-		UpdateTime: time.Now().Unix(),
-	})
+	// 核对迁移期间新增、修改和删除的源文件
+	if up.changes != nil {
+		if up.changes.reconciles() {
+			if err := up.reconcile(scanner, check); err != nil {
+				report.fail("upload", err)
+				return
+			}
+		}
+		summary.TotalFiles += up.changes.filesDelta
+		summary.TotalBytes += up.changes.bytesDelta
+		report.update(func(s *model.TaskStatus) {
+			s.TotalFiles = summary.TotalFiles
+			s.TotalBytes = summary.TotalBytes
+		})
+	}
 
-	// 3. 创建远程目录
-	supportsMkdir := true
-	createdDirs := make(map[string]bool)
-	sortedDirs := sortDirsByDepth(dirs)
-
-	for _, relDir := range sortedDirs {
-		if relDir == "" || createdDirs[relDir] {
-			continue
-		}
-		relPosix := toPosixPath(relDir)
-		remoteDir := storagePath + "/" + relPosix
-
-		if supportsMkdir {
-			err := zimaClient.CreateDir(req.BaseURL, token, remoteDir)
-			if err != nil {
-				if strings.Contains(err.Error(), "404") {
-					supportsMkdir = false
-				} else {
-					updateStatus(taskId, &model.TaskStatus{
-						TaskID:     taskId,
-						Status:     "error",
-						Step:       "upload",
-						Error:      err.Error(),
-						UpdateTime: time.Now().Unix(),
-					})
-					return
-				}
+	if check != nil {
+		// 链接清单中的路径与实际上传的路径保持一致
+		for i := range summary.Symlinks {
+			summary.Symlinks[i].Path = check.Resolve(summary.Symlinks[i].Path)
+		}
+		for i := range summary.HardLinks {
+			group := &summary.HardLinks[i]
+			group.Path = check.Resolve(group.Path)
+			for j := range group.Links {
+				group.Links[j] = check.Resolve(group.Links[j])
 			}
 		}
-		createdDirs[relDir] = true
+		summary.Renamed = append(summary.Renamed, check.Renamed...)
 	}
 
-	// 4. 上传文件
-	startMsg := "开始上传文件..."
-	if totalFiles == 0 {
-		startMsg = "无需上传文件"
+	// preserve 模式下上传链接清单
+	var manifestPath string
+	if req.Links == service.LinkPolicyPreserve && (len(summary.Symlinks) > 0 || len(summary.HardLinks) > 0) {
+		report.running("upload", "正在上传链接清单...")
+		manifestPath, err = uploadLinkManifest(dest, dstRoot, sourceInfo.Dir, summary)
+		if err != nil {
+			report.fail("upload", err)
+			return
+		}
+	}
+
+	// 元数据清单
+	var metadataPath string
+	if req.Metadata {
+		report.running("upload", "正在上传元数据清单...")
+		var symlinks []model.LinkRecord
+		if req.Links == service.LinkPolicyPreserve {
+			symlinks = summary.Symlinks
+		}
+		metadataPath, err = uploadMetadataSidecar(dest, dstRoot, sourceInfo.Dir, up, symlinks)
+		if err != nil {
+			report.fail("upload", err)
+			return
+		}
 	}
-	updateStatus(taskId, &model.TaskStatus{
-		TaskID:     taskId,
-		Status:     "running",
-		Step:       "upload",
-		Message:    startMsg,
-		TotalFiles: totalFiles,
-		UpdateTime: time.Now().Unix(),
-	})
 
-	for i, relPath := range files {
-		relPosix := toPosixPath(relPath)
-		fullPath := filepath.Join(sourceInfo.Dir, relPath)
-		dirName := filepath.Dir(relPosix)
+	// 目录内容全部上传后恢复目录修改时间
+	dirTimes := up.restoreDirTimes()
 
-		remoteDir := storagePath
-		if dirName != "." {
-			remoteDir = storagePath + "/" + dirName
+	// 4. 完成
+	result := model.MigrateResult{
+		DstPath:      dstRoot,
+		SourceDir:    sourceInfo.Dir,
+		SourceType:   sourceInfo.Type,
+		TotalFiles:   summary.TotalFiles,
+		TotalBytes:   summary.TotalBytes,
+		SkippedDirs:  summary.SkippedDirs,
+		ScanErrors:   summary.Errors,
+		Dirs:         summary.TopDirs(maxDirStats),
+		Skipped:      summary.Skipped,
+		SkippedCount: summary.SkippedCount,
+		Symlinks:     len(summary.Symlinks),
+		HardLinks:    summary.HardLinks,
+		LinkManifest: manifestPath,
+		DirTimes:     dirTimes,
+		MetadataFile: metadataPath,
+		Renamed:      summary.Renamed,
+		Collisions:   summary.Collisions,
+		SavedBytes:   up.savedBytes,
+	}
+	for i := range up.duplicates {
+		result.Duplicates = append(result.Duplicates, up.duplicates[i].Group())
+	}
+	if up.changes != nil {
+		result.Changed = up.changes.changed
+		result.ChangedCount = up.changes.count
+		for _, w := range up.changes.warnings() {
+			report.warn(w + "，明细见结果 changed")
 		}
-		filename := filepath.Base(relPosix)
+	}
+	if check != nil {
+		result.PathViolations = check.Violations
+		if check.ViolationCount > 0 {
+			report.warn(fmt.Sprintf("%d 个路径超过目标端长度限制，未上传，明细见结果 pathViolations", check.ViolationCount))
+		}
+	}
+	if summary.ErrorCount > 0 {
+		report.warn(fmt.Sprintf("扫描时跳过 %d 个无法读取的路径 (其中目录 %d 个)", summary.ErrorCount, summary.SkippedDirs))
+	}
+	if summary.SkippedCount > 0 {
+		report.warn(fmt.Sprintf("按策略跳过 %d 个路径 (符号链接、特殊文件或重名文件)，明细见结果 skipped", summary.SkippedCount))
+	}
+	if len(summary.Collisions) > 0 {
+		report.warn(fmt.Sprintf("%d 组文件在目标端重名，已按 %s 处理", len(summary.Collisions), summary.Collisions[0].Policy))
+	}
+	if len(summary.Renamed) > 0 {
+		report.warn(fmt.Sprintf("%d 个路径在目标端改名，对应关系见结果 renamed", len(summary.Renamed)))
+	}
+	if up.unchanged > 0 {
+		report.warn(fmt.Sprintf("%d 个目标端已存在且大小、修改时间相同的文件未重新上传", up.unchanged))
+	}
+	if up.retries > 0 {
+		report.warn(fmt.Sprintf("上传失败或停滞后共重试 %d 次，详情见任务日志", up.retries))
+	}
+	if up.copyFailed > 0 {
+		report.warn(fmt.Sprintf("%d 个重复文件在目标端复制失败，已直接上传", up.copyFailed))
+	}
+	if n := hardLinkDuplicates(summary.HardLinks); n > 0 {
+		report.warn(fmt.Sprintf("%d 个硬链接路径与其他文件内容相同，仅上传一次", n))
+	}
+	report.logf("迁移完成: %d 个文件", result.TotalFiles)
+	report.update(func(s *model.TaskStatus) {
+		s.Status = "success"
+		s.Step = "done"
+		s.Message = "迁移完成"
+		s.CurrentFile = ""
+		s.Result = &result
+	})
+}
 
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:           taskId,
-			Status:           "running",
-			Step:             "upload",
-			Message:          fmt.Sprintf("正在上传 %d/%d", i+1, totalFiles),
-			CurrentFile:      relPosix,
-			TransferredFiles: i,
-			TotalFiles:       totalFiles,
-			UpdateTime:       time.Now().Unix(),
+// scanThenUpload 先完整扫描 (并发)，完成容量预检后再上传
+func scanThenUpload(scanner *service.Scanner, up *uploader, sourceDir, capacityCheck string, spaceWatcher *service.SpaceWatcher, check *service.PathValidator, report *reporter) (*service.ScanSummary, error) {
+	scanResult, err := scanner.ScanWithProgress(sourceDir, func(p model.ScanProgress) {
+		report.update(func(s *model.TaskStatus) {
+			s.Scan = &p
+			s.Message = fmt.Sprintf("正在扫描目录：已发现 %d 个文件", p.FilesFound)
 		})
+	})
+	if err != nil {
+		report.fail("scan", err)
+		return nil, err
+	}
+	logDirStats(report, &scanResult.ScanSummary)
+
+	totalFiles := scanResult.TotalFiles
+	totalBytes := scanResult.TotalBytes
+	report.update(func(s *model.TaskStatus) {
+		s.Step = "scan"
+		s.Message = fmt.Sprintf("扫描完成：%d 个文件，共 %s", totalFiles, service.FormatBytes(totalBytes))
+		if scanResult.ErrorCount > 0 {
+			s.Message += fmt.Sprintf("，跳过 %d 个无法读取的路径", scanResult.ErrorCount)
+		}
+		s.TotalFiles = totalFiles
+		s.TotalBytes = totalBytes
+		s.Scan = &model.ScanProgress{
+			DirsVisited: int64(scanResult.TotalDirs + 1 - scanResult.SkippedDirs),
+			DirsFound:   int64(scanResult.TotalDirs),
+			FilesFound:  int64(totalFiles),
+			BytesFound:  totalBytes,
+			Errors:      int64(scanResult.ErrorCount),
+			Skipped:     int64(scanResult.SkippedCount),
+			Done:        true,
+		}
+	})
 
-		err := zimaClient.UploadFile(req.BaseURL, token, remoteDir, filename, fullPath)
-		if err != nil {
-			updateStatus(taskId, &model.TaskStatus{
-				TaskID:           taskId,
-				Status:           "error",
-				Step:             "upload",
-				CurrentFile:      relPosix,
-				TransferredFiles: i,
-				TotalFiles:       totalFiles,
-				Error:            err.Error(),
-				UpdateTime:       time.Now().Unix(),
+	// 记录首次扫描到的全部路径，结束前核对时据此识别新增的文件
+	if up.changes != nil {
+		up.changes.see(scanResult.Dirs...)
+		up.changes.see(scanResult.Files...)
+	}
+
+	// 上传前按目标端路径规则校验全部路径
+	if check != nil {
+		scanResult.Dirs = checkPaths(check, scanResult.Dirs)
+		scanResult.Files = checkPaths(check, scanResult.Files)
+		if err := check.Err(); err != nil {
+			report.fail("scan", err)
+			return nil, err
+		}
+		if check.SkippedFiles > 0 {
+			totalFiles -= check.SkippedFiles
+			totalBytes -= check.SkippedBytes
+			scanResult.TotalFiles, scanResult.TotalBytes = totalFiles, totalBytes
+			report.update(func(s *model.TaskStatus) {
+				s.TotalFiles = totalFiles
+				s.TotalBytes = totalBytes
 			})
-			return
 		}
+	}
 
-		updateStatus(taskId, &model.TaskStatus{
-			TaskID:           taskId,
-			Status:           "running",
-			Step:             "upload",
-			Message:          fmt.Sprintf("正在上传 %d/%d", i+1, totalFiles),
-			CurrentFile:      relPosix,
-			TransferredFiles: i + 1,
-			TotalFiles:       totalFiles,
-			UpdateTime:       time.Now().Unix(),
+	// 容量预检：比较源目录大小与目标存储可用空间
+	if spaceWatcher != nil {
+		if err := spaceWatcher.CheckTotal(totalBytes); err != nil {
+			if !errors.Is(err, service.ErrInsufficientSpace) || capacityCheck == service.CapacityCheckWarn {
+				// 无法获取可用空间或仅警告时继续上传
+				report.warn(err.Error())
+			} else {
+				report.fail("scan", err)
+				return nil, err
+			}
+		}
+	}
+
+	// 查找内容相同的文件，重复副本在原件上传后由目标端复制
+	if up.copier != nil {
+		report.running("scan", "正在查找重复文件...")
+		scanResult.Files, up.duplicates = service.FindDuplicates(sourceDir, scanResult.Files, func(hashed, total int) {
+			report.update(func(s *model.TaskStatus) {
+				s.Message = fmt.Sprintf("正在查找重复文件：已计算 %d/%d", hashed, total)
+			})
 		})
 	}
 
-	// 5. 完成
-	result := model.MigrateResult{
-		DstPath:    storagePath,
-		SourceDir:  sourceInfo.Dir,
-		SourceType: sourceInfo.Label,
-		TotalFiles: totalFiles,
-	}
-	updateStatus(taskId, &model.TaskStatus{
-		TaskID:           taskId,
-		Status:           "success",
-		Step:             "done",
-		Message:          "迁移完成",
-		TransferredFiles: totalFiles,
-		TotalFiles:       totalFiles,
-		Result:           &result,
-		UpdateTime:       time.Now().Unix(),
-	})
+	startMsg := "开始上传文件..."
+	if totalFiles == 0 {
+		startMsg = "无需上传文件"
+	}
+	report.running("upload", startMsg)
+
+	// 目录在前 (父目录先于子目录)，文件在后
+	entries := make(chan service.ScanEntry)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer close(entries)
+		for _, dir := range scanResult.Dirs {
+			select {
+			case entries <- dir:
+			case <-ctx.Done():
+				return
+			}
+		}
+		for _, file := range scanResult.Files {
+			select {
+			case entries <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if err := up.run(ctx, entries); err != nil {
+		report.fail("upload", err)
+		return nil, err
+	}
+	if err := up.copyDuplicates(); err != nil {
+		report.fail("upload", err)
+		return nil, err
+	}
+	return &scanResult.ScanSummary, nil
 }
 
-func updateStatus(taskId string, status *model.TaskStatus) {
-	statusFile := filepath.Join(StatusDir, fmt.Sprintf("ftoz-migrate-%s.json", taskId))
-	data, _ := json.Marshal(status)
-	os.WriteFile(statusFile, data, 0644)
+// logDirStats 扫描完成后将各一级子目录的统计记入任务日志
+func logDirStats(report *reporter, summary *service.ScanSummary) {
+	for _, dir := range summary.TopDirs(maxDirStats) {
+		report.logf("目录 %s: %d 个文件，%d 个子目录，%s", dir.Path, dir.Files, dir.Dirs, service.FormatBytes(dir.Bytes))
+	}
 }
 
-func resolveSource(sourceType, space string) *model.SourceInfo {
-	st := strings.TrimSpace(sourceType)
-	if st == "" {
-		st = strings.TrimSpace(space)
+// checkPaths 校验条目的目标端路径，返回保留的条目
+func checkPaths(check *service.PathValidator, entries []service.ScanEntry) []service.ScanEntry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		if check.Check(&entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// streamMigration 边扫描边上传，扫描到的条目立即交给上传协程
+// 路径校验为 report 模式时不使用 (须在上传前完成校验)，只处理 sanitize 模式下的修正和跳过
+func streamMigration(scanner *service.Scanner, up *uploader, sourceDir string, check *service.PathValidator, report *reporter) (*service.ScanSummary, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := scanner.Stream(ctx, sourceDir)
+	report.running("upload", "正在扫描并上传文件...")
+
+	entries := stream.Entries
+	checkDone := make(chan struct{})
+	if check != nil {
+		checked := make(chan service.ScanEntry)
+		entries = checked
+		go func() {
+			defer close(checkDone)
+			defer close(checked)
+			for entry := range stream.Entries {
+				if !check.Check(&entry) {
+					if up.changes != nil {
+						up.changes.see(entry)
+					}
+					continue
+				}
+				select {
+				case checked <- entry:
+				case <-ctx.Done():
+					stream.Cancel()
+					return
+				}
+			}
+		}()
+	} else {
+		close(checkDone)
 	}
 
-	if info, ok := SourceMap[st]; ok {
-		return &info
+	// 定期同步扫描进度，总数随扫描增长
+	progressDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-progressDone:
+				return
+			case <-ticker.C:
+				p := stream.Progress()
+				report.update(func(s *model.TaskStatus) {
+					s.Scan = &p
+					s.TotalFiles = int(p.FilesFound)
+					s.TotalBytes = p.BytesFound
+				})
+			}
+		}
+	}()
+
+	uploadErr := up.run(ctx, entries)
+	if uploadErr != nil {
+		stream.Cancel()
+		cancel()
 	}
+	<-checkDone
+	summary, scanErr := stream.Wait()
+	close(progressDone)
 
-	if st != "" {
-		return nil
+	if uploadErr != nil {
+		report.fail("upload", uploadErr)
+		return nil, uploadErr
 	}
+	if scanErr != nil {
+		report.fail("scan", scanErr)
+		return nil, scanErr
+	}
+	logDirStats(report, summary)
 
-	envDir := os.Getenv("SOURCE_DIR")
-	if envDir == "" {
-		envDir = DefaultSourceDir
+	if check != nil {
+		summary.TotalFiles -= check.SkippedFiles
+		summary.TotalBytes -= check.SkippedBytes
 	}
 
-	for _, info := range SourceMap {
-		if info.Dir == envDir {
-			return &info
+	p := stream.Progress()
+	p.Done = true
+	report.update(func(s *model.TaskStatus) {
+		s.Scan = &p
+		s.TotalFiles = summary.TotalFiles
+		s.TotalBytes = summary.TotalBytes
+	})
+	return summary, nil
+}
+
+// newDestination 根据请求创建目标端，同时返回目标根路径 (用于结果展示和路径长度校验)
+// transport 为连接 ZimaOS 使用的传输层
+func newDestination(req *model.MigrateRequest, transport http.RoundTripper) (service.Destination, string, error) {
+	switch req.DestType {
+	case "", service.DestTypeZimaOS:
+		if req.BaseURL == "" || req.Username == "" || req.Password == "" {
+			return nil, "", fmt.Errorf("缺少 baseUrl/username/password")
+		}
+		root := service.StorageRoot
+		if req.Storage != "" {
+			root = service.StorageRoot + "/" + req.Storage
+		}
+		dest := service.NewZimaOSDestination(req.BaseURL, req.Username, req.Password, req.Storage)
+		dest.SetTransport(transport)
+		if err := dest.SetAPIProfile(req.APIProfile); err != nil {
+			return nil, "", err
 		}
+		return dest, root, nil
+	case service.DestTypeLocal:
+		if !filepath.IsAbs(req.DestPath) {
+			return nil, "", fmt.Errorf("destPath 须为绝对路径")
+		}
+		root := filepath.Clean(req.DestPath)
+		return service.NewLocalDestination(root), root, nil
+	case service.DestTypeWebDAV:
+		if req.BaseURL == "" {
+			return nil, "", fmt.Errorf("缺少 WebDAV 地址 baseUrl")
+		}
+		root := "/" + strings.Trim(req.DestPath, "/")
+		dest, err := service.NewWebDAVDestination(req.BaseURL, req.Username, req.Password, root)
+		if err != nil {
+			return nil, "", err
+		}
+		return dest, root, nil
+	case service.DestTypeS3:
+		req.Bucket = strings.TrimSpace(req.Bucket)
+		if req.BaseURL == "" || req.Bucket == "" || req.Username == "" || req.Password == "" {
+			return nil, "", fmt.Errorf("缺少 baseUrl/bucket/username/password")
+		}
+		prefix := strings.Trim(req.DestPath, "/")
+		dest, err := service.NewS3Destination(req.BaseURL, req.Region, req.Bucket, req.Username, req.Password, prefix)
+		if err != nil {
+			return nil, "", err
+		}
+		return dest, strings.TrimSuffix("/"+req.Bucket+"/"+prefix, "/"), nil
+	case service.DestTypeSFTP:
+		if req.BaseURL == "" || req.Username == "" || (req.Password == "" && req.KeyFile == "") {
+			return nil, "", fmt.Errorf("缺少 baseUrl/username/password 或 keyFile")
+		}
+		root := req.DestPath
+		if root == "" {
+			root = "."
+		}
+		dest, err := service.NewSFTPDestination(service.SFTPConfig{
+			Addr:       req.BaseURL,
+			Username:   req.Username,
+			Password:   req.Password,
+			KeyFile:    req.KeyFile,
+			Passphrase: req.KeyPassphrase,
+			KnownHosts: req.KnownHosts,
+		}, root)
+		if err != nil {
+			return nil, "", err
+		}
+		return dest, root, nil
+	default:
+		return nil, "", fmt.Errorf("不支持的目标端类型: %s", req.DestType)
+	}
+}
+
+// uploadLinkManifest 将符号链接和硬链接记录写入目标目录根部的清单文件
+func uploadLinkManifest(dest service.Destination, dstRoot, sourceDir string, summary *service.ScanSummary) (string, error) {
+	manifest := service.LinkManifest{
+		Version:   1,
+		Source:    sourceDir,
+		Symlinks:  summary.Symlinks,
+		HardLinks: summary.HardLinks,
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
 	}
 
-	return &model.SourceInfo{Dir: envDir, Label: "custom"}
+	tmp, err := os.CreateTemp("", "ftoz-links-*.json")
+	if err != nil {
+		return "", fmt.Errorf("创建链接清单失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("写入链接清单失败: %w", err)
+	}
+
+	if err := dest.PutFile(service.LinkManifestName, tmp.Name()); err != nil {
+		return "", fmt.Errorf("上传链接清单失败: %w", err)
+	}
+	return dstRoot + "/" + service.LinkManifestName, nil
 }
 
-func sortDirsByDepth(dirs []string) []string {
-	sorted := make([]string, len(dirs))
-	copy(sorted, dirs)
-	sort.Slice(sorted, func(i, j int) bool {
-		return len(sorted[i]) < len(sorted[j])
-	})
-	return sorted
+// hardLinkDuplicates 统计未上传的硬链接重复路径数
+func hardLinkDuplicates(groups []model.HardLinkGroup) int {
+	n := 0
+	for _, g := range groups {
+		n += len(g.Links)
+	}
+	return n
+}
+
+func updateStatus(taskId string, status *model.TaskStatus) {
+	service.WriteTaskStatus(status)
 }
 
 func toPosixPath(p string) string {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+// restoreDirTimes 在全部内容上传后恢复目录修改时间，返回成功恢复的目录数
+// 上传文件会更新所在目录的修改时间，因此从最深的目录开始设置，子目录设置后不再影响父目录
+func (u *uploader) restoreDirTimes() int {
+	if u.dirTimer == nil || len(u.dirs) == 0 {
+		return 0
+	}
+	u.report.running("upload", "正在恢复目录修改时间...")
+
+	dirs := u.dirs
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i].DstPath, "/") > strings.Count(dirs[j].DstPath, "/")
+	})
+
+	restored, failed := 0, 0
+	for _, dir := range dirs {
+		relPosix := toPosixPath(dir.DstPath)
+		err := u.dirTimer.SetDirTime(relPosix, dir.ModTime)
+		if errors.Is(err, service.ErrDirTimeUnsupported) {
+			u.report.warn("目标端不支持设置目录修改时间，目录保留迁移时的时间")
+			return restored
+		}
+		if err != nil {
+			failed++
+			u.report.logf("%s 恢复目录修改时间失败: %v", relPosix, err)
+			continue
+		}
+		restored++
+	}
+	if failed > 0 {
+		u.report.warn(fmt.Sprintf("%d 个目录的修改时间恢复失败，详情见任务日志", failed))
+	}
+	return restored
+}
+
+// uploadMetadataSidecar 读取已上传路径的源端元数据，写入目标目录根部的元数据清单
+// 重复文件的副本和 preserve 模式下的符号链接同样记录
+func uploadMetadataSidecar(dest service.Destination, dstRoot, sourceDir string, up *uploader, symlinks []model.LinkRecord) (string, error) {
+	reader := service.NewMetadataReader(sourceDir)
+	sidecar := service.MetadataSidecar{
+		Version: 1,
+		Source:  sourceDir,
+		Created: time.Now().Unix(),
+		Entries: make([]service.PathMetadata, 0, len(up.metadata)+len(symlinks)),
+	}
+	for _, entry := range up.metadata {
+		sidecar.Entries = append(sidecar.Entries, reader.Read(entry.Path, entry.DstPath))
+	}
+	for _, set := range up.duplicates {
+		for _, file := range set.Copies {
+			sidecar.Entries = append(sidecar.Entries, reader.Read(file.Path, file.DstPath))
+		}
+	}
+	for _, link := range symlinks {
+		meta := reader.Read(link.Source, link.Path)
+		meta.Type = "symlink"
+		meta.Target = link.Target
+		sidecar.Entries = append(sidecar.Entries, meta)
+	}
+	sort.Slice(sidecar.Entries, func(i, j int) bool {
+		return sidecar.Entries[i].Path < sidecar.Entries[j].Path
+	})
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp("", "ftoz-metadata-*.json")
+	if err != nil {
+		return "", fmt.Errorf("创建元数据清单失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("写入元数据清单失败: %w", err)
+	}
+
+	if err := dest.PutFile(service.MetadataSidecarName, tmp.Name()); err != nil {
+		return "", fmt.Errorf("上传元数据清单失败: %w", err)
+	}
+	return dstRoot + "/" + service.MetadataSidecarName, nil
+}
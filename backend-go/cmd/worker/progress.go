@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+// reporter 维护任务状态并写入状态文件，可在多个协程中使用
+type reporter struct {
+	taskId string
+	mu     sync.Mutex
+	status model.TaskStatus
+}
+
+// newReporter 创建状态报告器，沿用接口创建任务时记录的开始时间
+func newReporter(taskId string) *reporter {
+	r := &reporter{taskId: taskId, status: model.TaskStatus{TaskID: taskId, StartTime: time.Now().Unix()}}
+	if prev, err := service.ReadTaskStatus(taskId); err == nil && prev.StartTime > 0 {
+		r.status.StartTime = prev.StartTime
+	}
+	return r
+}
+
+// update 修改任务状态并写入状态文件
+func (r *reporter) update(fn func(s *model.TaskStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn(&r.status)
+	r.status.QueuePosition = 0
+	r.status.UpdateTime = time.Now().Unix()
+	service.WriteTaskStatus(&r.status)
+}
+
+// logf 追加一行任务日志，写入失败时输出到标准错误
+func (r *reporter) logf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if err := service.AppendTaskLog(r.taskId, msg); err != nil {
+		fmt.Fprintln(os.Stderr, "写入任务日志失败:", err)
+	}
+}
+
+// running 更新运行中的步骤和提示信息
+func (r *reporter) running(step, message string) {
+	r.logf("%s", message)
+	r.update(func(s *model.TaskStatus) {
+		s.Status = "running"
+		s.Step = step
+		s.Message = message
+	})
+}
+
+// fail 标记任务失败
+func (r *reporter) fail(step string, err error) {
+	r.failMsg(step, err.Error())
+}
+
+// failMsg 以指定错误信息标记任务失败
+func (r *reporter) failMsg(step, msg string) {
+	r.logf("失败: %s", msg)
+	r.update(func(s *model.TaskStatus) {
+		s.Status = "error"
+		if step != "" {
+			s.Step = step
+		}
+		s.Error = msg
+	})
+}
+
+// warn 追加一条警告
+func (r *reporter) warn(msg string) {
+	r.logf("警告: %s", msg)
+	r.update(func(s *model.TaskStatus) {
+		s.Warnings = append(s.Warnings, msg)
+	})
+}
+
+// snapshot 返回当前状态的副本
+func (r *reporter) snapshot() model.TaskStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+const (
+	// pullPollInterval 查询服务端任务进度的间隔
+	pullPollInterval = 2 * time.Second
+	// pullMountTimeout 等待 ZimaOS 挂载共享的时间
+	pullMountTimeout = 30 * time.Second
+	// pullMaxPollErrors 连续查询失败次数上限
+	pullMaxPollErrors = 30
+)
+
+// runPull 拉取模式：通过临时 WebDAV 共享暴露源目录，在 ZimaOS 上创建远程连接并启动服务端导入，
+// 将导入任务的进度同步到任务状态。结束后删除远程连接并停止共享
+func runPull(client *service.ZimaOSClient, req *model.MigrateRequest, token, dstRoot string, source *model.SourceInfo, report *reporter) {
+	// 1. 启动共享服务
+	report.running("scan", "正在启动共享服务...")
+
+	host := strings.Trim(req.PullHost, "[]")
+	if host == "" {
+		var err error
+		if host, err = service.AdvertiseHost(req.BaseURL); err != nil {
+			report.fail("scan", err)
+			return
+		}
+	}
+	share, err := service.StartPullShare(source.Dir, host, req.PullPort, req.Links)
+	if err != nil {
+		report.fail("scan", err)
+		return
+	}
+	defer share.Close()
+
+	// 2. 在 ZimaOS 上挂载共享
+	report.running("scan", fmt.Sprintf("正在挂载共享 %s ...", share.URL(host)))
+	conn, err := client.CreateConnect(req.BaseURL, token, share.Origin(host), strconv.Itoa(share.Port), share.Username, share.Password)
+	if err != nil {
+		report.fail("scan", fmt.Errorf("ZimaOS 挂载共享失败: %w", err))
+		return
+	}
+	defer func() {
+		if err := client.DeleteConnect(req.BaseURL, token, conn.ID); err != nil {
+			report.warn("删除 ZimaOS 远程连接失败: " + err.Error())
+		}
+	}()
+
+	// 挂载可能需要一些时间，等待挂载点可读
+	var entries []service.RemoteFile
+	deadline := time.Now().Add(pullMountTimeout)
+	for {
+		entries, err = client.ListFiles(req.BaseURL, token, conn.MountPoint)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(pullPollInterval)
+	}
+	if err != nil {
+		report.fail("scan", fmt.Errorf("读取挂载点 %s 失败: %w", conn.MountPoint, err))
+		return
+	}
+
+	result := model.MigrateResult{
+		DstPath:    dstRoot,
+		SourceDir:  source.Dir,
+		SourceType: source.Type,
+	}
+	if len(entries) == 0 {
+		report.update(func(s *model.TaskStatus) {
+			s.Status = "success"
+			s.Step = "done"
+			s.Message = "无需上传文件"
+			s.Result = &result
+		})
+		return
+	}
+
+	// 3. 启动服务端导入，导入挂载点下的全部条目
+	src := make([]string, 0, len(entries))
+	for _, e := range entries {
+		src = append(src, e.Path)
+	}
+	userSelect := service.UserSelectOverwrite
+	if req.Sync {
+		userSelect = service.UserSelectSkip
+	}
+	task, err := client.ImportFiles(req.BaseURL, token, src, dstRoot, userSelect)
+	if err != nil {
+		report.fail("upload", err)
+		return
+	}
+	report.running("upload", "ZimaOS 正在导入文件...")
+
+	// 4. 同步服务端任务进度
+	pollErrors := 0
+	for !task.Finished() {
+		time.Sleep(pullPollInterval)
+		latest, err := client.GetTask(req.BaseURL, token, task.ID)
+		if err != nil {
+			if pollErrors++; pollErrors >= pullMaxPollErrors {
+				report.fail("upload", fmt.Errorf("查询导入任务失败: %w", err))
+				return
+			}
+			continue
+		}
+		pollErrors = 0
+		task = latest
+		report.update(func(s *model.TaskStatus) {
+			s.TotalFiles = int(task.TotalItem)
+			s.TotalBytes = task.TotalSize
+			s.TransferredFiles = int(task.ProcessedItem)
+			s.TransferredBytes = task.ProcessedSize
+			switch task.Status {
+			case service.ZimaTaskPending:
+				s.Message = "ZimaOS 导入任务排队中..."
+			case service.ZimaTaskCalculating:
+				s.Message = "ZimaOS 正在统计文件..."
+			default:
+				s.Message = fmt.Sprintf("ZimaOS 正在导入 %d/%d (%d%%，%s/s)",
+					task.ProcessedItem, task.TotalItem, task.Progress, service.FormatBytes(task.Speed))
+			}
+		})
+	}
+
+	switch task.Status {
+	case service.ZimaTaskFailed:
+		msg := task.ErrMsg
+		if msg == "" {
+			msg = "未知错误"
+		}
+		report.failMsg("upload", "ZimaOS 导入失败: "+msg)
+		return
+	case service.ZimaTaskCancelled:
+		report.failMsg("upload", "ZimaOS 导入任务已取消")
+		return
+	}
+
+	// 5. 完成
+	result.TotalFiles = int(task.TotalItem)
+	result.TotalBytes = task.TotalSize
+	report.logf("迁移完成: %d 个文件", result.TotalFiles)
+	report.update(func(s *model.TaskStatus) {
+		s.Status = "success"
+		s.Step = "done"
+		s.Message = "迁移完成"
+		s.CurrentFile = ""
+		s.TotalFiles = int(task.TotalItem)
+		s.TotalBytes = task.TotalSize
+		s.TransferredFiles = int(task.TotalItem)
+		s.TransferredBytes = task.TotalSize
+		s.Result = &result
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+const (
+	// controlPollInterval 检查控制文件的间隔
+	controlPollInterval = 5 * time.Second
+	// windowPollInterval 暂停期间重新检查传输时段的间隔，时段被修改时能及时恢复
+	windowPollInterval = 30 * time.Second
+)
+
+// throttle 上传限速和传输时段
+// 参数来自迁移请求，运行中通过控制文件修改 (见 control 接口)，无需重启任务
+type throttle struct {
+	taskId  string
+	report  *reporter
+	limiter *service.RateLimiter
+
+	mu       sync.Mutex
+	schedule service.TransferSchedule
+	modTime  int64 // 已应用的控制文件修改时间
+}
+
+// newThrottle 创建限速器并设置为全部目标端共用
+// 排队期间已通过接口修改过参数时以控制文件为准，否则将请求参数写入控制文件
+func newThrottle(taskId string, req *model.MigrateRequest, report *reporter) *throttle {
+	t := &throttle{
+		taskId:  taskId,
+		report:  report,
+		limiter: service.NewRateLimiter(0),
+	}
+	service.SetUploadLimiter(t.limiter)
+	t.limiter.SetGate(t.allowed)
+
+	if control, modTime, err := service.ReadTaskControl(taskId); err == nil {
+		t.apply(control, modTime)
+		return t
+	}
+	control := &model.TaskControl{RateLimit: req.RateLimit, Windows: req.Windows}
+	t.apply(control, 0)
+	if err := service.WriteTaskControl(taskId, control); err != nil {
+		report.warn("写入任务控制文件失败，运行中无法调整限速: " + err.Error())
+	}
+	return t
+}
+
+// apply 应用控制参数，时段无效时保留原时段
+func (t *throttle) apply(control *model.TaskControl, modTime int64) {
+	schedule, err := service.ParseSchedule(control.Windows)
+	if err != nil {
+		t.report.warn(err.Error())
+		return
+	}
+	t.limiter.SetRate(control.RateLimit)
+
+	t.mu.Lock()
+	t.schedule = schedule
+	t.modTime = modTime
+	t.mu.Unlock()
+
+	t.report.update(func(s *model.TaskStatus) {
+		s.RateLimit = control.RateLimit
+		s.Windows = control.Windows
+	})
+}
+
+// watch 定期检查控制文件，有变化时应用新参数，直到 ctx 结束
+func (t *throttle) watch(ctx context.Context) {
+	ticker := time.NewTicker(controlPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			control, modTime, err := service.ReadTaskControl(t.taskId)
+			t.mu.Lock()
+			changed := err == nil && modTime != t.modTime
+			t.mu.Unlock()
+			if changed {
+				t.apply(control, modTime)
+			}
+		}
+	}
+}
+
+// allowed 当前是否在允许的传输时段
+func (t *throttle) allowed() bool {
+	t.mu.Lock()
+	schedule := t.schedule
+	t.mu.Unlock()
+	return schedule.Allowed(time.Now())
+}
+
+// waitWindow 不在允许的传输时段时暂停，直到进入时段或 ctx 结束
+func (t *throttle) waitWindow(ctx context.Context) {
+	paused := false
+	for {
+		t.mu.Lock()
+		schedule := t.schedule
+		t.mu.Unlock()
+
+		now := time.Now()
+		if schedule.Allowed(now) {
+			if paused {
+				t.report.update(func(s *model.TaskStatus) {
+					s.Status = "running"
+					s.Message = "已进入传输时段，继续上传"
+				})
+			}
+			return
+		}
+
+		next := schedule.NextStart(now)
+		if !paused {
+			paused = true
+			t.report.update(func(s *model.TaskStatus) {
+				s.Status = "paused"
+				s.Message = fmt.Sprintf("不在允许的传输时段，已暂停，将于 %s 继续", next.Format("01-02 15:04"))
+			})
+		}
+
+		wait := time.Until(next)
+		if wait > windowPollInterval {
+			wait = windowPollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
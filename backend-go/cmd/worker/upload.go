@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+)
+
+// uploader 消费扫描条目并上传到目标端
+// 目录在调度协程中按顺序创建 (父目录先于子条目到达)，文件交给多个上传协程并发处理。
+// 开启打包上传时，小文件按所在目录累积成批，整批交给上传协程
+type uploader struct {
+	dest        service.Destination
+	sourceDir   string
+	concurrency int
+	report      *reporter
+	space       *service.SpaceWatcher
+	sync        bool // 跳过目标端已是最新的文件
+
+	createdDirs map[string]bool
+	dirs        []service.ScanEntry // 已创建的目录，上传完成后恢复修改时间
+	dirTimer    service.DirTimer    // 设置目标端目录修改时间，nil 表示不支持
+	metadata    []service.ScanEntry // 写入元数据清单的条目，nil 表示不生成清单
+	changes     *changeTracker      // 检查源文件在迁移过程中的变化，nil 表示不检查
+
+	mu        sync.Mutex
+	listings  map[string]map[string]service.DestEntry // sync 模式下已列出的目标端目录
+	unchanged int                                     // 目标端已是最新而跳过的文件数
+	retries   int                                     // 上传失败后重试的次数
+
+	batch    *batcher  // 小文件打包上传，nil 表示不打包
+	throttle *throttle // 上传限速和传输时段，nil 表示不限制
+
+	copier     service.Copier         // 去重时在目标端复制重复文件，nil 表示不去重
+	duplicates []service.DuplicateSet // 原件上传后需要复制的重复文件
+	savedBytes int64                  // 通过目标端复制节省的传输字节数
+	copyFailed int                    // 复制失败改为直接上传的文件数
+}
+
+// uploadRetryDelays 上传失败后每次重试前的等待时间，重试次数即其长度
+var uploadRetryDelays = []time.Duration{5 * time.Second, 15 * time.Second}
+
+// uploadJob 上传协程的任务：单个文件或一批小文件
+type uploadJob struct {
+	file  service.ScanEntry
+	batch *fileBatch
+}
+
+func newUploader(dest service.Destination, sourceDir string, concurrency int, report *reporter) *uploader {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &uploader{
+		dest:        dest,
+		sourceDir:   sourceDir,
+		concurrency: concurrency,
+		report:      report,
+		createdDirs: make(map[string]bool),
+		listings:    make(map[string]map[string]service.DestEntry),
+	}
+}
+
+// run 处理 entries 直到通道关闭，任一文件上传失败时停止并返回错误
+func (u *uploader) run(ctx context.Context, entries <-chan service.ScanEntry) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan uploadJob)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < u.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				// 不在允许的传输时段时暂停等待，扫描结束后一次提交的剩余批次同样检查
+				if u.throttle != nil {
+					u.throttle.waitWindow(ctx)
+				}
+				var err error
+				if job.batch != nil {
+					err = u.uploadBatch(job.batch)
+				} else {
+					err = u.uploadFile(job.file)
+				}
+				if err != nil {
+					setErr(err)
+				}
+			}
+		}()
+	}
+
+	send := func(job uploadJob) bool {
+		select {
+		case jobs <- job:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+dispatch:
+	for {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case entry, ok := <-entries:
+			if !ok {
+				// 扫描结束，上传剩余的批次
+				if u.batch != nil {
+					for _, b := range u.batch.flushAll() {
+						if !send(uploadJob{batch: b}) {
+							break
+						}
+					}
+				}
+				break dispatch
+			}
+			if u.metadata != nil {
+				u.metadata = append(u.metadata, entry)
+			}
+			if u.changes != nil {
+				u.changes.see(entry)
+			}
+			if entry.IsDir {
+				if err := u.createDir(entry.DstPath); err != nil {
+					setErr(err)
+					break dispatch
+				}
+				if u.dirTimer != nil {
+					u.dirs = append(u.dirs, entry)
+				}
+				continue
+			}
+
+			// 上传前确认目标存储空间充足，不足时暂停等待
+			if u.space != nil {
+				err := u.space.Wait(entry.Size, func(free int64) {
+					u.report.update(func(s *model.TaskStatus) {
+						s.Status = "paused"
+						s.Message = fmt.Sprintf("目标存储可用空间不足 (剩余 %s)，已暂停，等待释放空间...", service.FormatBytes(free))
+					})
+				})
+				if err != nil {
+					// 查询失败不影响上传，仅记录警告
+					u.report.warn(err.Error())
+				}
+			}
+
+			if u.batch != nil && u.batch.accepts(entry) {
+				for _, b := range u.batch.add(entry) {
+					if !send(uploadJob{batch: b}) {
+						break dispatch
+					}
+				}
+				continue
+			}
+			if !send(uploadJob{file: entry}) {
+				break dispatch
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// createDir 创建目标端目录，relDir 为目标端相对路径
+func (u *uploader) createDir(relDir string) error {
+	if relDir == "" || u.createdDirs[relDir] {
+		return nil
+	}
+	if err := u.dest.Mkdir(toPosixPath(relDir)); err != nil {
+		return err
+	}
+	u.createdDirs[relDir] = true
+	return nil
+}
+
+// uploadFile 上传单个文件并更新进度
+func (u *uploader) uploadFile(file service.ScanEntry) error {
+	relPosix := toPosixPath(file.DstPath)
+
+	u.report.update(func(s *model.TaskStatus) {
+		s.Status = "running"
+		s.Step = "upload"
+		s.Message = fmt.Sprintf("正在上传 %d/%d", s.TransferredFiles+1, s.TotalFiles)
+		s.CurrentFile = relPosix
+	})
+
+	if u.skipUnchanged(file) {
+		return nil
+	}
+
+	sent, err := u.sendFile(&file)
+	if err != nil {
+		u.report.update(func(s *model.TaskStatus) {
+			s.CurrentFile = relPosix
+		})
+		return err
+	}
+	if !sent {
+		return nil
+	}
+
+	if u.space != nil {
+		u.space.Consume(file.Size)
+	}
+
+	u.report.update(func(s *model.TaskStatus) {
+		s.TransferredFiles++
+		s.TransferredBytes += file.Size
+		s.Message = fmt.Sprintf("正在上传 %d/%d", s.TransferredFiles, s.TotalFiles)
+	})
+	return nil
+}
+
+// putFile 上传文件，失败 (包括上传停滞被中止) 时等待后重试，每次失败记入任务日志
+func (u *uploader) putFile(relPosix, fullPath string) error {
+	for attempt := 0; ; attempt++ {
+		err := u.dest.PutFile(relPosix, fullPath)
+		if err == nil {
+			if attempt > 0 {
+				u.report.logf("%s 第 %d 次重试上传成功", relPosix, attempt)
+			}
+			return nil
+		}
+		// 传输时段结束时上传被中止，进入时段后重新上传，不计入重试次数
+		if u.throttle != nil && !u.throttle.allowed() {
+			u.report.logf("%s 传输时段已结束，上传中止，进入时段后重新上传", relPosix)
+			u.throttle.waitWindow(context.Background())
+			attempt--
+			continue
+		}
+		if attempt >= len(uploadRetryDelays) {
+			u.report.logf("%s 上传失败，已重试 %d 次: %v", relPosix, attempt, err)
+			return err
+		}
+
+		delay := uploadRetryDelays[attempt]
+		if errors.Is(err, service.ErrStalled) {
+			u.report.logf("%s 上传停滞，%s 后重试 (%d/%d): %v", relPosix, delay, attempt+1, len(uploadRetryDelays), err)
+		} else {
+			u.report.logf("%s 上传失败，%s 后重试 (%d/%d): %v", relPosix, delay, attempt+1, len(uploadRetryDelays), err)
+		}
+		u.mu.Lock()
+		u.retries++
+		u.mu.Unlock()
+		time.Sleep(delay)
+	}
+}
+
+// skipUnchanged sync 模式下目标端已是最新时计为已传输，返回是否跳过
+func (u *uploader) skipUnchanged(file service.ScanEntry) bool {
+	if !u.sync || !u.upToDate(toPosixPath(file.DstPath), file) {
+		return false
+	}
+	u.mu.Lock()
+	u.unchanged++
+	u.mu.Unlock()
+	if u.changes != nil {
+		u.changes.record(file)
+	}
+	u.report.update(func(s *model.TaskStatus) {
+		s.TransferredFiles++
+		s.TransferredBytes += file.Size
+		s.Message = fmt.Sprintf("正在上传 %d/%d", s.TransferredFiles, s.TotalFiles)
+	})
+	return true
+}
+
+// upToDate 判断目标端是否已有大小和修改时间 (精确到秒) 相同的文件
+// 目标端只提供上传时间时，上传时间不早于本地修改时间即视为最新。
+// 每个目标目录只列出一次，列出失败时视为不存在
+func (u *uploader) upToDate(relPosix string, file service.ScanEntry) bool {
+	dir, name := path.Split(relPosix)
+	dir = strings.TrimSuffix(dir, "/")
+
+	u.mu.Lock()
+	listing, ok := u.listings[dir]
+	u.mu.Unlock()
+	if !ok {
+		listing = make(map[string]service.DestEntry)
+		if entries, err := u.dest.List(dir); err == nil {
+			for _, e := range entries {
+				listing[e.Name] = e
+			}
+		}
+		u.mu.Lock()
+		u.listings[dir] = listing
+		u.mu.Unlock()
+	}
+
+	existing, ok := listing[name]
+	if !ok || existing.IsDir || existing.Size != file.Size {
+		return false
+	}
+	if existing.Uploaded {
+		return existing.ModTime.Unix() >= file.ModTime.Unix()
+	}
+	return existing.ModTime.Unix() == file.ModTime.Unix()
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"ftoz/internal/model"
+)
+
+const (
+	// DefaultPath 默认配置文件路径 (位于应用数据目录)
+	DefaultPath = "/var/apps/ftoz/var/config.json"
+	// DefaultMaxRunning 默认同时运行的迁移任务数
+	DefaultMaxRunning = 1
+
+	// 目标端路径规则默认值 (兼容 ZimaOS 的 Samba 共享及 ext4 限制)
+	DefaultIllegalChars = `\:*?"<>|`
+	DefaultMaxNameBytes = 255
+	DefaultMaxPathBytes = 4095
+)
+
+// Config 应用配置
+type Config struct {
+	Queue   QueueConfig    `json:"queue"`
+	Sources []SourceConfig `json:"sources"`
+	Paths   PathRules      `json:"paths"`
+
+	Transport model.TransportOptions `json:"transport"` // 连接 ZimaOS 的默认 HTTP 传输选项
+}
+
+// QueueConfig 任务队列配置
+type QueueConfig struct {
+	MaxRunning int `json:"maxRunning"`
+}
+
+// PathRules 目标端路径规则
+type PathRules struct {
+	IllegalChars string `json:"illegalChars"` // 文件名中不允许出现的字符 (控制字符总是不允许)
+	MaxNameBytes int    `json:"maxNameBytes"` // 单个文件名的最大字节数
+	MaxPathBytes int    `json:"maxPathBytes"` // 完整远程路径的最大字节数
+}
+
+// SourceConfig 自定义迁移源目录
+type SourceConfig struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Dir   string `json:"dir"`
+}
+
+// Load 加载配置文件，文件不存在时使用默认值
+// 配置文件路径可通过 FTOZ_CONFIG 环境变量修改
+func Load() (*Config, error) {
+	cfg := &Config{}
+
+	path := os.Getenv("FTOZ_CONFIG")
+	if path == "" {
+		path = DefaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// 环境变量优先于配置文件
+	if v, err := strconv.Atoi(os.Getenv("FTOZ_MAX_RUNNING")); err == nil {
+		cfg.Queue.MaxRunning = v
+	}
+	if cfg.Queue.MaxRunning <= 0 {
+		cfg.Queue.MaxRunning = DefaultMaxRunning
+	}
+
+	if cfg.Paths.IllegalChars == "" {
+		cfg.Paths.IllegalChars = DefaultIllegalChars
+	}
+	if cfg.Paths.MaxNameBytes <= 0 {
+		cfg.Paths.MaxNameBytes = DefaultMaxNameBytes
+	}
+	if cfg.Paths.MaxPathBytes <= 0 {
+		cfg.Paths.MaxPathBytes = DefaultMaxPathBytes
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ftoz/internal/config"
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConnectionHandler 连接测试处理器
+type ConnectionHandler struct{}
+
+// NewConnectionHandler 创建连接测试处理器
+func NewConnectionHandler() *ConnectionHandler {
+	return &ConnectionHandler{}
+}
+
+// Handle Gin 处理函数
+func (h *ConnectionHandler) Handle(c *gin.Context) {
+	var req model.ConnectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请求参数解析失败",
+			"data": nil,
+		})
+		return
+	}
+
+	h.handleTest(c.Writer, &req)
+}
+
+// HandleHTTP 标准 HTTP 处理函数 (用于 CGI)
+func (h *ConnectionHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
+	var req model.ConnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"code":400,"msg":"请求参数解析失败","data":null}`))
+		return
+	}
+
+	h.handleTest(w, &req)
+}
+
+func (h *ConnectionHandler) handleTest(w http.ResponseWriter, req *model.ConnectRequest) {
+	req.BaseURL = strings.TrimRight(strings.TrimSpace(req.BaseURL), "/")
+	req.Username = strings.TrimSpace(req.Username)
+	if req.BaseURL == "" || req.Username == "" || req.Password == "" {
+		h.writeJSON(w, 400, "缺少 baseUrl/username/password", nil)
+		return
+	}
+
+	client, err := newZimaClient(req)
+	if err != nil {
+		h.writeJSON(w, 400, err.Error(), nil)
+		return
+	}
+	report := client.TestConnection(req.BaseURL, req.Username, req.Password)
+	if !report.OK {
+		h.writeJSON(w, 200, "连接测试未通过", report)
+		return
+	}
+	h.writeJSON(w, 200, "连接测试通过", report)
+}
+
+// newZimaClient 按请求中的传输选项 (覆盖配置文件默认值) 和 API 版本创建 ZimaOS 客户端
+func newZimaClient(req *model.ConnectRequest) (*service.ZimaOSClient, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("读取配置失败: %w", err)
+	}
+	transport, err := service.NewHTTPTransport(service.MergeTransportOptions(cfg.Transport, req.Transport), 1)
+	if err != nil {
+		return nil, err
+	}
+	client := service.NewZimaOSClient()
+	client.SetTransport(transport)
+	if err := client.SetAPIProfile(req.APIProfile); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (h *ConnectionHandler) writeJSON(w http.ResponseWriter, code int, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(model.Response{
+		Code: code,
+		Msg:  msg,
+		Data: data,
+	})
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ControlHandler 运行中任务的限速和传输时段调整处理器
+// GET 查询当前参数，POST 修改参数；worker 定期读取控制文件，修改在数秒内生效
+type ControlHandler struct{}
+
+// NewControlHandler 创建任务控制处理器
+func NewControlHandler() *ControlHandler {
+	return &ControlHandler{}
+}
+
+// Handle Gin 处理函数
+func (h *ControlHandler) Handle(c *gin.Context) {
+	if c.Request.Method == http.MethodGet {
+		h.handleGet(c.Writer, c.Query("taskId"))
+		return
+	}
+
+	var req model.ControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请求参数解析失败",
+			"data": nil,
+		})
+		return
+	}
+
+	h.handleUpdate(c.Writer, &req)
+}
+
+// HandleHTTP 标准 HTTP 处理函数 (用于 CGI)
+func (h *ControlHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		h.handleGet(w, r.URL.Query().Get("taskId"))
+		return
+	}
+
+	var req model.ControlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"code":400,"msg":"请求参数解析失败","data":null}`))
+		return
+	}
+
+	h.handleUpdate(w, &req)
+}
+
+func (h *ControlHandler) handleGet(w http.ResponseWriter, taskId string) {
+	if taskId == "" {
+		h.writeJSON(w, 400, "缺少 taskId 参数", nil)
+		return
+	}
+	control, _, err := service.ReadTaskControl(taskId)
+	if err != nil {
+		// 任务尚未开始运行时从状态文件判断是否存在
+		if _, statusErr := service.ReadTaskStatus(taskId); statusErr != nil {
+			h.writeJSON(w, 404, "任务不存在", nil)
+			return
+		}
+		control = &model.TaskControl{}
+	}
+	h.writeJSON(w, 200, "操作成功", control)
+}
+
+func (h *ControlHandler) handleUpdate(w http.ResponseWriter, req *model.ControlRequest) {
+	if req.TaskID == "" {
+		h.writeJSON(w, 400, "缺少 taskId 参数", nil)
+		return
+	}
+
+	status, err := service.ReadTaskStatus(req.TaskID)
+	if err != nil {
+		h.writeJSON(w, 404, "任务不存在", nil)
+		return
+	}
+	if status.Status == "success" || status.Status == "error" {
+		h.writeJSON(w, 400, "任务已结束", nil)
+		return
+	}
+
+	// 在现有参数上修改，未提供的字段保持不变
+	control, _, err := service.ReadTaskControl(req.TaskID)
+	if err != nil {
+		control = &model.TaskControl{}
+	}
+	if req.RateLimit != nil {
+		if *req.RateLimit < 0 {
+			h.writeJSON(w, 400, "rateLimit 无效", nil)
+			return
+		}
+		control.RateLimit = *req.RateLimit
+	}
+	if req.Windows != nil {
+		if _, err := service.ParseSchedule(*req.Windows); err != nil {
+			h.writeJSON(w, 400, err.Error(), nil)
+			return
+		}
+		control.Windows = *req.Windows
+	}
+
+	if err := service.WriteTaskControl(req.TaskID, control); err != nil {
+		h.writeJSON(w, 500, "写入任务控制文件失败: "+err.Error(), nil)
+		return
+	}
+	h.writeJSON(w, 200, "操作成功", control)
+}
+
+func (h *ControlHandler) writeJSON(w http.ResponseWriter, code int, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(model.Response{
+		Code: code,
+		Msg:  msg,
+		Data: data,
+	})
+}
@@ -13,6 +13,11 @@ type Handler struct {
 	dirHandler     *DirHandler
 	readHandler    *ReadHandler
 	saveHandler    *SaveHandler
+	sourceHandler  *SourceHandler
+	storageHandler *StorageHandler
+	connHandler    *ConnectionHandler
+	controlHandler *ControlHandler
+	logHandler     *LogHandler
 }
 
 // New 创建处理器
@@ -23,6 +28,11 @@ func New() *Handler {
 		dirHandler:     NewDirHandler(),
 		readHandler:    NewReadHandler(),
 		saveHandler:    NewSaveHandler(),
+		sourceHandler:  NewSourceHandler(),
+		storageHandler: NewStorageHandler(),
+		connHandler:    NewConnectionHandler(),
+		controlHandler: NewControlHandler(),
+		logHandler:     NewLogHandler(),
 	}
 }
 
@@ -51,6 +61,31 @@ func (h *Handler) Save(c *gin.Context) {
 	h.saveHandler.Handle(c)
 }
 
+// Sources 迁移源目录列表接口
+func (h *Handler) Sources(c *gin.Context) {
+	h.sourceHandler.Handle(c)
+}
+
+// Storages ZimaOS 存储列表接口
+func (h *Handler) Storages(c *gin.Context) {
+	h.storageHandler.Handle(c)
+}
+
+// TestConnection 连接测试接口
+func (h *Handler) TestConnection(c *gin.Context) {
+	h.connHandler.Handle(c)
+}
+
+// Control 任务限速和传输时段调整接口
+func (h *Handler) Control(c *gin.Context) {
+	h.controlHandler.Handle(c)
+}
+
+// Log 任务日志查询接口
+func (h *Handler) Log(c *gin.Context) {
+	h.logHandler.Handle(c)
+}
+
 // Dispatch 根据 api-path 或 _api 参数分发请求
 func (h *Handler) Dispatch(c *gin.Context) {
 	api := c.GetHeader("api-path")
@@ -69,6 +104,16 @@ func (h *Handler) Dispatch(c *gin.Context) {
 		h.Read(c)
 	case "save":
 		h.Save(c)
+	case "sources":
+		h.Sources(c)
+	case "storages":
+		h.Storages(c)
+	case "test-connection":
+		h.TestConnection(c)
+	case "control":
+		h.Control(c)
+	case "log":
+		h.Log(c)
 	default:
 		c.JSON(http.StatusNotFound, gin.H{
 			"code": 404,
@@ -91,6 +136,16 @@ func (h *Handler) HandleAPI(w http.ResponseWriter, r *http.Request, api string)
 		h.readHandler.HandleHTTP(w, r)
 	case "save":
 		h.saveHandler.HandleHTTP(w, r)
+	case "sources":
+		h.sourceHandler.HandleHTTP(w, r)
+	case "storages":
+		h.storageHandler.HandleHTTP(w, r)
+	case "test-connection":
+		h.connHandler.HandleHTTP(w, r)
+	case "control":
+		h.controlHandler.HandleHTTP(w, r)
+	case "log":
+		h.logHandler.HandleHTTP(w, r)
 	default:
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Write([]byte(`{"code":404,"msg":"不存在的接口","data":null}`))
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLogLines 未指定 lines 时返回的日志行数
+const defaultLogLines = 200
+
+// LogHandler 任务日志查询处理器
+type LogHandler struct{}
+
+// NewLogHandler 创建任务日志处理器
+func NewLogHandler() *LogHandler {
+	return &LogHandler{}
+}
+
+// Handle Gin 处理函数
+func (h *LogHandler) Handle(c *gin.Context) {
+	h.handleLog(c.Writer, c.Query("taskId"), c.Query("lines"))
+}
+
+// HandleHTTP 标准 HTTP 处理函数 (用于 CGI)
+func (h *LogHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	h.handleLog(w, query.Get("taskId"), query.Get("lines"))
+}
+
+func (h *LogHandler) handleLog(w http.ResponseWriter, taskId, linesParam string) {
+	if taskId == "" {
+		h.writeJSON(w, 400, "缺少 taskId 参数", nil)
+		return
+	}
+
+	lines := defaultLogLines
+	if linesParam != "" {
+		n, err := strconv.Atoi(linesParam)
+		if err != nil || n < 0 {
+			h.writeJSON(w, 400, "lines 参数无效", nil)
+			return
+		}
+		lines = n
+	}
+
+	if _, err := service.ReadTaskStatus(taskId); err != nil {
+		h.writeJSON(w, 404, "任务不存在", nil)
+		return
+	}
+
+	logs, err := service.ReadTaskLog(taskId, lines)
+	if err != nil {
+		h.writeJSON(w, 500, "读取任务日志失败: "+err.Error(), nil)
+		return
+	}
+	h.writeJSON(w, 200, "操作成功", logs)
+}
+
+func (h *LogHandler) writeJSON(w http.ResponseWriter, code int, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(model.Response{
+		Code: code,
+		Msg:  msg,
+		Data: data,
+	})
+}
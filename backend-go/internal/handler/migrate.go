@@ -4,30 +4,26 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"ftoz/internal/config"
 	"ftoz/internal/model"
+	"ftoz/internal/service"
+	"ftoz/internal/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 const (
-	DefaultSourceDir = "/vol1/1000"
-	StatusDir        = "/tmp"
-	WorkerPath       = "/var/apps/ftoz/target/server/worker"
+	WorkerPath = "/var/apps/ftoz/target/server/worker"
 )
 
-var SourceMap = map[string]model.SourceInfo{
-	"personal": {Dir: "/vol1/1000", Label: "personal"},
-	"team":     {Dir: "/vol1/@team", Label: "team"},
-}
-
 // MigrateHandler 迁移处理器
 type MigrateHandler struct{}
 
@@ -70,8 +66,29 @@ func (h *MigrateHandler) handleMigrate(w http.ResponseWriter, req *model.Migrate
 		return
 	}
 
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		h.writeJSON(w, 500, "读取配置失败: "+err.Error(), nil)
+		return
+	}
+
+	// 提前检查传输选项 (CA 证书、指纹、代理)，避免任务排队后才失败
+	if _, err := service.NewHTTPTransport(service.MergeTransportOptions(cfg.Transport, req.Transport), 1); err != nil {
+		h.writeJSON(w, 400, err.Error(), nil)
+		return
+	}
+
+	// 提前校验 ZimaOS 存储名称，避免名称写错时任务排队后才失败
+	if (req.DestType == "" || req.DestType == service.DestTypeZimaOS) && req.Storage != "" {
+		if err := h.checkStorage(req); err != nil {
+			h.writeJSON(w, 400, err.Error(), nil)
+			return
+		}
+	}
+
 	// 解析源目录
-	sourceInfo := h.resolveSource(req.Source, req.Space)
+	sourceInfo := service.NewSourceRegistry(cfg).Resolve(req.Source, req.Space)
 	if sourceInfo == nil {
 		h.writeJSON(w, 400, "未知的迁移空间", req)
 		return
@@ -88,6 +105,12 @@ func (h *MigrateHandler) handleMigrate(w http.ResponseWriter, req *model.Migrate
 		return
 	}
 
+	// 下载任务的目标为本地目录
+	destKey := service.DestinationKey(req)
+	if req.Direction == service.DirectionDownload {
+		destKey = service.LocalDestinationKey(filepath.Join(sourceInfo.Dir, req.LocalPath))
+	}
+
 	// 生成任务ID
 	taskId := generateTaskId()
 
@@ -95,36 +118,47 @@ func (h *MigrateHandler) handleMigrate(w http.ResponseWriter, req *model.Migrate
 	status := model.TaskStatus{
 		TaskID:     taskId,
 		Status:     "pending",
+		Step:       "queue",
 		Message:    "任务已创建，等待执行",
 		StartTime:  time.Now().Unix(),
 		UpdateTime: time.Now().Unix(),
 	}
-	if err := writeStatusFile(taskId, &status); err != nil {
+	if err := service.WriteTaskStatus(&status); err != nil {
 		h.writeJSON(w, 500, "创建状态文件失败: "+err.Error(), nil)
 		return
 	}
 
-	// 启动后台进程
+	// 加入任务队列，由队列按并发上限启动后台进程
 	paramsJson, _ := json.Marshal(req)
-	cmd := exec.Command(WorkerPath, taskId, string(paramsJson))
-
-	// 设置进程独立运行，不受父进程影响
-	cmd.Stdin = nil
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-
-	if err := cmd.Start(); err != nil {
+	queue := service.NewTaskQueue(WorkerPath, cfg.Queue.MaxRunning)
+	position, err := queue.Enqueue(service.QueueEntry{
+		TaskID:  taskId,
+		DestKey: destKey,
+		Params:  string(paramsJson),
+	}, req.OnBusy)
+	if err != nil {
 		// 更新状态为错误
 		status.Status = "error"
-		status.Error = "启动后台进程失败: " + err.Error()
+		status.Error = "加入任务队列失败: " + err.Error()
 		status.UpdateTime = time.Now().Unix()
-		writeStatusFile(taskId, &status)
+		service.WriteTaskStatus(&status)
 
+		if errors.Is(err, service.ErrDestinationBusy) {
+			h.writeJSON(w, 409, err.Error(), nil)
+			return
+		}
 		h.writeJSON(w, 500, "启动迁移任务失败: "+err.Error(), nil)
 		return
 	}
 
 	// 立即返回任务ID
+	if position > 0 {
+		h.writeJSON(w, 200, fmt.Sprintf("迁移任务已加入队列，前方还有 %d 个任务", position-1), gin.H{
+			"taskId":        taskId,
+			"queuePosition": position,
+		})
+		return
+	}
 	h.writeJSON(w, 200, "迁移任务已启动", gin.H{"taskId": taskId})
 }
 
@@ -132,41 +166,163 @@ func (h *MigrateHandler) validateRequest(req *model.MigrateRequest) error {
 	req.BaseURL = strings.TrimRight(strings.TrimSpace(req.BaseURL), "/")
 	req.Username = strings.TrimSpace(req.Username)
 	req.Storage = strings.Trim(strings.TrimSpace(req.Storage), "/")
-
-	if req.BaseURL == "" || req.Username == "" || req.Password == "" {
-		return fmt.Errorf("缺少 baseUrl/username/password")
+	req.OnBusy = strings.TrimSpace(req.OnBusy)
+	req.DestPath = strings.TrimSpace(req.DestPath)
+
+	// 校验目标端参数，下载模式总是从 ZimaOS 读取
+	switch req.DestType {
+	case "", service.DestTypeZimaOS:
+		if req.BaseURL == "" || req.Username == "" || req.Password == "" {
+			return fmt.Errorf("缺少 baseUrl/username/password")
+		}
+		if !validStoragePath(req.Storage) {
+			return fmt.Errorf("storage 无效: %s", req.Storage)
+		}
+	case service.DestTypeLocal:
+		if !filepath.IsAbs(req.DestPath) {
+			return fmt.Errorf("destPath 须为绝对路径")
+		}
+	case service.DestTypeWebDAV:
+		if req.BaseURL == "" {
+			return fmt.Errorf("缺少 WebDAV 地址 baseUrl")
+		}
+	case service.DestTypeS3:
+		req.Bucket = strings.TrimSpace(req.Bucket)
+		if req.BaseURL == "" || req.Bucket == "" || req.Username == "" || req.Password == "" {
+			return fmt.Errorf("缺少 baseUrl/bucket/username/password")
+		}
+	case service.DestTypeSFTP:
+		if req.BaseURL == "" || req.Username == "" || (req.Password == "" && req.KeyFile == "") {
+			return fmt.Errorf("缺少 baseUrl/username/password 或 keyFile")
+		}
+	default:
+		return fmt.Errorf("destType 仅支持 zimaos/local/webdav/s3/sftp")
+	}
+	if req.DestType != "" && req.DestType != service.DestTypeZimaOS && req.Direction == service.DirectionDownload {
+		return fmt.Errorf("下载模式仅支持 ZimaOS")
+	}
+	if req.Pull && ((req.DestType != "" && req.DestType != service.DestTypeZimaOS) || req.Direction == service.DirectionDownload) {
+		return fmt.Errorf("拉取模式仅支持上传到 ZimaOS")
+	}
+	if req.PullPort < 0 || req.PullPort > 65535 {
+		return fmt.Errorf("pullPort 无效")
+	}
+	if req.RateLimit < 0 {
+		return fmt.Errorf("rateLimit 无效")
+	}
+	if _, err := service.ParseSchedule(req.Windows); err != nil {
+		return err
+	}
+	if !service.ValidAPIProfile(req.APIProfile) {
+		return fmt.Errorf("apiProfile 仅支持 auto/zimaos/casaos")
+	}
+	if req.StallTimeout < 0 && req.StallTimeout != service.StallTimeoutDisabled {
+		return fmt.Errorf("stallTimeout 无效，-1 表示不检测停滞")
+	}
+	if req.BatchThreshold < 0 {
+		return fmt.Errorf("batchThreshold 无效")
+	}
+	if req.BatchThreshold > 0 && req.DestType != "" && req.DestType != service.DestTypeZimaOS {
+		return fmt.Errorf("打包上传仅支持 ZimaOS")
+	}
+	if req.Dedup && req.DestType != "" && req.DestType != service.DestTypeZimaOS {
+		return fmt.Errorf("去重仅支持 ZimaOS")
+	}
+	if req.Dedup && req.Stream {
+		return fmt.Errorf("去重需要先完整扫描，不能与 stream 同时使用")
+	}
+	switch req.BatchFormat {
+	case "", service.ArchiveFormatZip, service.ArchiveFormatTar:
+	default:
+		return fmt.Errorf("batchFormat 仅支持 zip/tar")
+	}
+	if req.OnBusy != "" && req.OnBusy != service.BusyPolicyQueue && req.OnBusy != service.BusyPolicyReject {
+		return fmt.Errorf("onBusy 仅支持 queue/reject")
+	}
+	switch req.CapacityCheck {
+	case "", service.CapacityCheckStrict, service.CapacityCheckWarn, service.CapacityCheckOff:
+	default:
+		return fmt.Errorf("capacityCheck 仅支持 strict/warn/off")
+	}
+	switch req.Links {
+	case "", service.LinkPolicySkip, service.LinkPolicyFollow, service.LinkPolicyPreserve:
+	default:
+		return fmt.Errorf("links 仅支持 skip/follow/preserve")
+	}
+	req.NameCharset = strings.ToLower(strings.TrimSpace(req.NameCharset))
+	if req.NameCharset != "" && req.NameCharset != service.NameCharsetAuto && req.NameCharset != service.NameCharsetNone && util.GetEncoding(req.NameCharset) == nil {
+		return fmt.Errorf("不支持的 nameCharset: %s", req.NameCharset)
+	}
+	switch req.Normalize {
+	case "", service.NormalizeNFC, service.NormalizeNone:
+	default:
+		return fmt.Errorf("normalize 仅支持 nfc/none")
+	}
+	switch req.OnCollision {
+	case "", service.CollisionRename, service.CollisionSkip, service.CollisionFail:
+	default:
+		return fmt.Errorf("onCollision 仅支持 rename/skip/fail")
+	}
+	switch req.OnChange {
+	case "", service.ChangePolicyReupload, service.ChangePolicyWarn, service.ChangePolicyOff:
+	default:
+		return fmt.Errorf("onChange 仅支持 reupload/warn/off")
+	}
+	switch req.Direction {
+	case "", service.DirectionUpload, service.DirectionDownload:
+	default:
+		return fmt.Errorf("direction 仅支持 upload/download")
+	}
+	if req.LocalPath != "" {
+		req.LocalPath = filepath.Clean(strings.TrimSpace(req.LocalPath))
+		if !filepath.IsLocal(req.LocalPath) {
+			return fmt.Errorf("localPath 须为迁移空间下的相对路径")
+		}
+	}
+	req.RemotePath = strings.TrimSpace(req.RemotePath)
+	switch req.PathCheck {
+	case "", service.PathCheckSanitize, service.PathCheckReport, service.PathCheckOff:
+	default:
+		return fmt.Errorf("pathCheck 仅支持 sanitize/report/off")
 	}
 	return nil
 }
 
-func (h *MigrateHandler) resolveSource(sourceType, space string) *model.SourceInfo {
-	// 兼容 source 和 space 参数
-	st := strings.TrimSpace(sourceType)
-	if st == "" {
-		st = strings.TrimSpace(space)
+// checkStorage 登录 ZimaOS 并确认存储存在
+func (h *MigrateHandler) checkStorage(req *model.MigrateRequest) error {
+	client, err := newZimaClient(&model.ConnectRequest{
+		BaseURL:    req.BaseURL,
+		Username:   req.Username,
+		Password:   req.Password,
+		Transport:  req.Transport,
+		APIProfile: req.APIProfile,
+	})
+	if err != nil {
+		return err
 	}
-
-	if info, ok := SourceMap[st]; ok {
-		return &info
+	token, err := client.Login(req.BaseURL, req.Username, req.Password)
+	if err != nil {
+		return err
 	}
+	return client.ValidateStorage(req.BaseURL, token, req.Storage)
+}
 
-	if st != "" {
-		return nil
+// validStoragePath 检查存储名称 (可带子目录) 的格式：不含空段、. 和 ..、反斜杠及控制字符
+func validStoragePath(storage string) bool {
+	if storage == "" {
+		return true
 	}
-
-	// 默认源目录
-	envDir := os.Getenv("SOURCE_DIR")
-	if envDir == "" {
-		envDir = DefaultSourceDir
+	for _, part := range strings.Split(storage, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
 	}
-
-	for _, info := range SourceMap {
-		if info.Dir == envDir {
-			return &info
+	for _, r := range storage {
+		if r < 0x20 || r == 0x7f || r == '\\' {
+			return false
 		}
 	}
-
-	return &model.SourceInfo{Dir: envDir, Label: "custom"}
+	return true
 }
 
 func (h *MigrateHandler) writeJSON(w http.ResponseWriter, code int, msg string, data interface{}) {
@@ -184,27 +340,3 @@ func generateTaskId() string {
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
-
-// writeStatusFile 写入状态文件
-func writeStatusFile(taskId string, status *model.TaskStatus) error {
-	statusFile := filepath.Join(StatusDir, fmt.Sprintf("ftoz-migrate-%s.json", taskId))
-	data, err := json.Marshal(status)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(statusFile, data, 0644)
-}
-
-// readStatusFile 读取状态文件
-func readStatusFile(taskId string) (*model.TaskStatus, error) {
-	statusFile := filepath.Join(StatusDir, fmt.Sprintf("ftoz-migrate-%s.json", taskId))
-	data, err := os.ReadFile(statusFile)
-	if err != nil {
-		return nil, err
-	}
-	var status model.TaskStatus
-	if err := json.Unmarshal(data, &status); err != nil {
-		return nil, err
-	}
-	return &status, nil
-}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ftoz/internal/config"
+	"ftoz/internal/model"
+	"ftoz/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SourceHandler 迁移源目录列表处理器
+type SourceHandler struct{}
+
+// NewSourceHandler 创建源目录处理器
+func NewSourceHandler() *SourceHandler {
+	return &SourceHandler{}
+}
+
+// Handle Gin 处理函数
+func (h *SourceHandler) Handle(c *gin.Context) {
+	withSize := c.Query("size") == "1" || c.Query("size") == "true"
+	h.handleSources(c.Writer, withSize)
+}
+
+// HandleHTTP 标准 HTTP 处理函数 (用于 CGI)
+func (h *SourceHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
+	size := r.URL.Query().Get("size")
+	h.handleSources(w, size == "1" || size == "true")
+}
+
+func (h *SourceHandler) handleSources(w http.ResponseWriter, withSize bool) {
+	cfg, err := config.Load()
+	if err != nil {
+		h.writeJSON(w, 500, "读取配置失败: "+err.Error(), nil)
+		return
+	}
+
+	registry := service.NewSourceRegistry(cfg)
+	sources := registry.List()
+	for i := range sources {
+		// 统计目录大小需要遍历整个目录，仅在 size=1 时计算
+		registry.FillUsage(&sources[i], withSize)
+	}
+
+	h.writeJSON(w, 200, "操作成功", sources)
+}
+
+func (h *SourceHandler) writeJSON(w http.ResponseWriter, code int, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(model.Response{
+		Code: code,
+		Msg:  msg,
+		Data: data,
+	})
+}
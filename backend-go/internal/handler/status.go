@@ -2,9 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
+	"ftoz/internal/config"
 	"ftoz/internal/model"
+	"ftoz/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,12 +39,31 @@ func (h *StatusHandler) handleStatus(w http.ResponseWriter, taskId string) {
 	}
 
 	// 读取状态文件
-	status, err := readStatusFile(taskId)
+	status, err := service.ReadTaskStatus(taskId)
 	if err != nil {
 		h.writeJSON(w, 404, "任务不存在", nil)
 		return
 	}
 
+	// 未结束的任务先清理已退出的 worker，崩溃的 worker 不会一直占用队列
+	if status.Status == "pending" || status.Status == "running" || status.Status == "paused" {
+		if cfg, err := config.Load(); err == nil {
+			if service.NewTaskQueue(WorkerPath, cfg.Queue.MaxRunning).Reap() == nil {
+				if s, err := service.ReadTaskStatus(taskId); err == nil {
+					status = s
+				}
+			}
+		}
+	}
+
+	// 排队中的任务补充实时排队位置
+	if status.Status == "pending" {
+		if position := service.NewTaskQueue(WorkerPath, 0).Position(taskId); position > 0 {
+			status.QueuePosition = position
+			status.Message = fmt.Sprintf("排队中，前方还有 %d 个任务", position-1)
+		}
+	}
+
 	h.writeJSON(w, 200, "操作成功", status)
 }
 
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ftoz/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorageHandler ZimaOS 存储列表处理器
+type StorageHandler struct{}
+
+// NewStorageHandler 创建存储列表处理器
+func NewStorageHandler() *StorageHandler {
+	return &StorageHandler{}
+}
+
+// Handle Gin 处理函数
+func (h *StorageHandler) Handle(c *gin.Context) {
+	var req model.ConnectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请求参数解析失败",
+			"data": nil,
+		})
+		return
+	}
+
+	h.handleStorages(c.Writer, &req)
+}
+
+// HandleHTTP 标准 HTTP 处理函数 (用于 CGI)
+func (h *StorageHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
+	var req model.ConnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"code":400,"msg":"请求参数解析失败","data":null}`))
+		return
+	}
+
+	h.handleStorages(w, &req)
+}
+
+func (h *StorageHandler) handleStorages(w http.ResponseWriter, req *model.ConnectRequest) {
+	req.BaseURL = strings.TrimRight(strings.TrimSpace(req.BaseURL), "/")
+	req.Username = strings.TrimSpace(req.Username)
+	if req.BaseURL == "" || req.Username == "" || req.Password == "" {
+		h.writeJSON(w, 400, "缺少 baseUrl/username/password", nil)
+		return
+	}
+
+	zimaClient, err := newZimaClient(req)
+	if err != nil {
+		h.writeJSON(w, 400, err.Error(), nil)
+		return
+	}
+	token, err := zimaClient.Login(req.BaseURL, req.Username, req.Password)
+	if err != nil {
+		h.writeJSON(w, 401, err.Error(), nil)
+		return
+	}
+
+	storages, err := zimaClient.ListStorages(req.BaseURL, token, true)
+	if err != nil {
+		h.writeJSON(w, 500, "获取存储列表失败: "+err.Error(), nil)
+		return
+	}
+
+	h.writeJSON(w, 200, "操作成功", storages)
+}
+
+func (h *StorageHandler) writeJSON(w http.ResponseWriter, code int, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(model.Response{
+		Code: code,
+		Msg:  msg,
+		Data: data,
+	})
+}
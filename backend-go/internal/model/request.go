@@ -7,7 +7,77 @@ type MigrateRequest struct {
 	Password string `json:"password"`
 	Storage  string `json:"storage"`
 	Source   string `json:"source"`
-	Space    string `json:"space"` // 兼容旧参数名
+	Space    string `json:"space"`  // 兼容旧参数名
+	OnBusy   string `json:"onBusy"` // 目标目录被占用时: queue(默认)/reject
+
+	CapacityCheck string `json:"capacityCheck"` // 容量检查: strict(默认)/warn/off
+	ReserveBytes  int64  `json:"reserveBytes"`  // 目标存储保留空间，默认 1 GiB
+
+	Stream        bool   `json:"stream"`        // 边扫描边上传 (不做容量预检)
+	ScanWorkers   int    `json:"scanWorkers"`   // 并发扫描协程数，默认按 CPU 核数
+	StrictScan    bool   `json:"strictScan"`    // 遇到无法读取的目录时立即失败
+	Links         string `json:"links"`         // 符号链接处理: skip(默认)/follow/preserve
+	OneFileSystem bool   `json:"oneFileSystem"` // 不跨越源目录所在的文件系统
+	NameCharset   string `json:"nameCharset"`   // 非 UTF-8 文件名的编码: auto(默认)/none/gbk/big5 等
+	Normalize     string `json:"normalize"`     // 文件名 Unicode 规范化: nfc(默认)/none
+	OnCollision   string `json:"onCollision"`   // 重名处理: rename(默认)/skip/fail
+	PathCheck     string `json:"pathCheck"`     // 目标端路径校验: sanitize(默认)/report/off
+	Concurrency   int    `json:"concurrency"`   // 并发上传数，默认 1
+	Sync          bool   `json:"sync"`          // 跳过目标端已存在且大小、修改时间相同的文件
+	Metadata      bool   `json:"metadata"`      // 在目标目录根部写入元数据清单 (权限、属主、扩展属性、链接目标)
+	OnChange      string `json:"onChange"`      // 上传后是否重新扫描源目录核对变化: reupload/warn/off(默认)；上传前后的变化检查总是进行，warn 时只记录
+
+	DestType      string `json:"destType"`      // 目标端类型: zimaos(默认)/local/webdav/s3/sftp
+	DestPath      string `json:"destPath"`      // local: 目标目录 (绝对路径)，如 USB 硬盘挂载目录; webdav/sftp: 服务器上的目标目录; s3: 对象键前缀
+	Bucket        string `json:"bucket"`        // s3: 存储桶
+	Region        string `json:"region"`        // s3: 区域，默认 us-east-1
+	KeyFile       string `json:"keyFile"`       // sftp: 私钥文件路径
+	KeyPassphrase string `json:"keyPassphrase"` // sftp: 私钥密码
+	KnownHosts    string `json:"knownHosts"`    // sftp: known_hosts 文件路径，默认 ~/.ssh/known_hosts
+
+	Pull     bool   `json:"pull"`     // 拉取模式: 通过临时 WebDAV 共享由 ZimaOS 服务端导入
+	PullHost string `json:"pullHost"` // pull: ZimaOS 访问本机使用的地址，默认自动检测
+	PullPort int    `json:"pullPort"` // pull: 共享服务端口，默认随机
+
+	BatchThreshold int64  `json:"batchThreshold"` // 小于该大小 (字节) 的文件打包上传后由 ZimaOS 解压，0 表示不打包
+	BatchFormat    string `json:"batchFormat"`    // 打包格式: zip(默认)/tar
+	Dedup          bool   `json:"dedup"`          // 内容相同的文件只上传一次，其余副本由 ZimaOS 服务端复制
+
+	RateLimit int64    `json:"rateLimit"` // 上传限速 (字节/秒)，所有并发上传共用，0 表示不限速
+	Windows   []string `json:"windows"`   // 允许传输的时段，如 ["22:00-07:00"]，为空时不限制
+
+	StallTimeout int `json:"stallTimeout"` // 上传停滞判定时间 (秒)，超过该时间没有数据传输时中止并重试，0 为默认 120，-1 不检测
+
+	Direction  string `json:"direction"`  // 迁移方向: upload(默认，FNOS → ZimaOS)/download(ZimaOS → FNOS)
+	RemotePath string `json:"remotePath"` // download: ZimaOS 上的源目录，默认为 storage 对应目录
+	LocalPath  string `json:"localPath"`  // download: 迁移空间下的目标子目录，默认为空间根目录
+
+	Transport  *TransportOptions `json:"transport"`  // 连接 ZimaOS 的 HTTP 传输选项，覆盖配置文件中的默认值
+	APIProfile string            `json:"apiProfile"` // 服务端 API 版本: auto(默认)/zimaos/casaos
+}
+
+// ConnectRequest ZimaOS 连接参数
+type ConnectRequest struct {
+	BaseURL    string            `json:"baseUrl"`
+	Username   string            `json:"username"`
+	Password   string            `json:"password"`
+	Transport  *TransportOptions `json:"transport"`
+	APIProfile string            `json:"apiProfile"`
+}
+
+// TransportOptions 连接 ZimaOS 的 HTTP 传输选项，时间单位为秒，0 表示使用默认值
+type TransportOptions struct {
+	CAFile             string   `json:"caFile"`             // 额外信任的 CA 证书文件 (PEM)
+	CACert             string   `json:"caCert"`             // 额外信任的 CA 证书内容 (PEM)
+	Pins               []string `json:"pins"`               // 证书公钥 SHA-256 指纹 (base64 或十六进制)，设置后只接受匹配的证书
+	InsecureSkipVerify bool     `json:"insecureSkipVerify"` // 不校验服务端证书
+	Proxy              string   `json:"proxy"`              // 代理地址，如 http://host:3128；为空时使用环境变量，direct 表示不使用代理
+	HTTP2              bool     `json:"http2"`              // HTTPS 连接尝试使用 HTTP/2
+
+	DialTimeout     int `json:"dialTimeout"`     // 建立 TCP 连接超时，默认 10
+	TLSTimeout      int `json:"tlsTimeout"`      // TLS 握手超时，默认 10
+	ResponseTimeout int `json:"responseTimeout"` // 请求发送完后等待响应头的超时，默认不限制
+	IdleTimeout     int `json:"idleTimeout"`     // 空闲连接保留时间，默认 90
 }
 
 // DirRequest 目录读取请求参数
@@ -31,6 +101,19 @@ type SaveRequest struct {
 
 // SourceInfo 源目录信息
 type SourceInfo struct {
-	Dir   string
-	Label string
+	ID    string `json:"id"`              // 源标识，作为 MigrateRequest.Source 传入
+	Type  string `json:"type"`            // personal/team/volume/custom
+	Label string `json:"label"`           // 显示名称
+	Dir   string `json:"dir"`             // 本地目录
+	Owner string `json:"owner,omitempty"` // 个人空间所属用户
+	Size  int64  `json:"size,omitempty"`  // 目录占用空间 (字节)
+	Total int64  `json:"total,omitempty"` // 所在存储卷总容量 (字节)
+	Free  int64  `json:"free,omitempty"`  // 所在存储卷可用空间 (字节)
+}
+
+// ControlRequest 调整运行中任务的限速和传输时段，未提供的字段保持不变
+type ControlRequest struct {
+	TaskID    string    `json:"taskId"`
+	RateLimit *int64    `json:"rateLimit"`
+	Windows   *[]string `json:"windows"`
 }
@@ -35,6 +35,104 @@ type MigrateResult struct {
 	SourceDir  string `json:"sourceDir"`
 	SourceType string `json:"sourceType"`
 	TotalFiles int    `json:"totalFiles"`
+	TotalBytes int64  `json:"totalBytes"`
+
+	SkippedDirs int         `json:"skippedDirs,omitempty"` // 扫描时无法读取而跳过的目录数
+	ScanErrors  []ScanError `json:"scanErrors,omitempty"`  // 扫描时跳过的路径及原因
+	Dirs        []DirStat   `json:"dirs,omitempty"`        // 源目录下各一级子目录的统计，按大小降序
+
+	Skipped      []SkippedPath   `json:"skipped,omitempty"`      // 按策略跳过的路径
+	SkippedCount int             `json:"skippedCount,omitempty"` // 按策略跳过的路径总数
+	Symlinks     int             `json:"symlinks,omitempty"`     // 记录到清单中的符号链接数
+	HardLinks    []HardLinkGroup `json:"hardLinks,omitempty"`    // 硬链接组，重复路径未上传
+	LinkManifest string          `json:"linkManifest,omitempty"` // 目标端链接清单路径
+
+	DirTimes     int    `json:"dirTimes,omitempty"`     // 已恢复修改时间的目录数
+	MetadataFile string `json:"metadataFile,omitempty"` // 目标端元数据清单路径
+
+	Duplicates []DuplicateGroup `json:"duplicates,omitempty"` // 内容相同的文件组，副本由目标端复制
+	SavedBytes int64            `json:"savedBytes,omitempty"` // 去重节省的传输字节数
+
+	Renamed    []PathMapping   `json:"renamed,omitempty"`    // 在目标端改名的路径
+	Collisions []NameCollision `json:"collisions,omitempty"` // 规范化或忽略大小写后重名的文件
+
+	PathViolations []PathViolation `json:"pathViolations,omitempty"` // 不符合目标端路径规则的路径
+
+	Changed      []ChangedPath `json:"changed,omitempty"`      // 迁移过程中变化的源文件 (最多保留 1000 条)
+	ChangedCount int           `json:"changedCount,omitempty"` // 变化的源文件总数
+}
+
+// ChangedPath 迁移过程中变化的源文件
+type ChangedPath struct {
+	Path     string `json:"path"`     // 相对源目录的路径
+	DstPath  string `json:"dstPath"`  // 目标端相对路径
+	Change   string `json:"change"`   // modified/unstable/added/removed
+	Uploaded bool   `json:"uploaded"` // 目标端是否为变化后的内容 (removed 时表示目标端仍保留该文件)
+}
+
+// PathViolation 不符合目标端路径规则的路径
+type PathViolation struct {
+	Path    string `json:"path"`    // 源路径
+	DstPath string `json:"dstPath"` // 目标端相对路径
+	Rule    string `json:"rule"`    // illegal-char/trailing/name-length/path-length，多条以逗号分隔
+	Detail  string `json:"detail"`
+}
+
+// NameCollision 同一目录下在目标端重名的文件
+type NameCollision struct {
+	Dir    string   `json:"dir"`    // 所在目录 (目标端相对路径)
+	Names  []string `json:"names"`  // 重名的源文件名，第一个保留原名
+	Policy string   `json:"policy"` // 处理方式: rename/skip/fail
+}
+
+// PathMapping 源路径与目标端路径的对应关系
+type PathMapping struct {
+	Path    string `json:"path"`    // 源路径 (无效的 UTF-8 字节转义为 \xNN)
+	DstPath string `json:"dstPath"` // 目标端相对路径
+	Reason  string `json:"reason"`  // 改名原因，如 charset:gbk
+}
+
+// SkippedPath 按策略跳过的路径 (符号链接、特殊文件、循环链接、重名文件等)
+type SkippedPath struct {
+	Path   string `json:"path"`   // 相对源目录的路径
+	Type   string `json:"type"`   // symlink/dir/file/device/pipe/socket
+	Reason string `json:"reason"` // 跳过原因
+}
+
+// LinkRecord 符号链接及其目标
+type LinkRecord struct {
+	Path   string `json:"path"`   // 相对源目录的路径
+	Target string `json:"target"` // 链接目标 (原样保存)
+	Source string `json:"-"`      // 源相对路径，用于读取元数据
+}
+
+// HardLinkGroup 指向同一 inode 的文件，仅上传 Path，Links 为重复路径
+type HardLinkGroup struct {
+	Path  string   `json:"path"`
+	Links []string `json:"links"`
+}
+
+// DuplicateGroup 内容相同的文件，仅上传 Path，Copies 在目标端复制
+type DuplicateGroup struct {
+	Hash   string   `json:"hash"` // SHA-256
+	Size   int64    `json:"size"`
+	Path   string   `json:"path"`
+	Copies []string `json:"copies"`
+}
+
+// DirStat 目录统计 (包含所有子目录)
+type DirStat struct {
+	Path  string `json:"path"` // 相对源目录的路径
+	Files int    `json:"files"`
+	Dirs  int    `json:"dirs"`
+	Bytes int64  `json:"bytes"`
+}
+
+// ScanError 扫描时跳过的路径
+type ScanError struct {
+	Path  string `json:"path"` // 相对源目录的路径
+	IsDir bool   `json:"isDir"`
+	Error string `json:"error"`
 }
 
 // ErrorEvent SSE 错误事件
@@ -43,17 +141,69 @@ type ErrorEvent struct {
 	Message string `json:"message"`
 }
 
+// ScanProgress 扫描进度
+type ScanProgress struct {
+	DirsVisited int64 `json:"dirsVisited"`
+	DirsFound   int64 `json:"dirsFound"`
+	FilesFound  int64 `json:"filesFound"`
+	BytesFound  int64 `json:"bytesFound"`
+	Errors      int64 `json:"errors"`  // 无法读取而跳过的路径数
+	Skipped     int64 `json:"skipped"` // 按策略跳过的路径数
+	Done        bool  `json:"done"`
+}
+
 // TaskStatus 迁移任务状态 (用于后台任务 + 轮询模式)
 type TaskStatus struct {
 	TaskID           string         `json:"taskId"`
-	Status           string         `json:"status"` // pending/running/success/error
+	Status           string         `json:"status"` // pending/running/paused/success/error
 	Step             string         `json:"step"`   // login/scan/upload
 	Message          string         `json:"message"`
 	CurrentFile      string         `json:"currentFile,omitempty"`
 	TransferredFiles int            `json:"transferredFiles"`
 	TotalFiles       int            `json:"totalFiles"`
+	TransferredBytes int64          `json:"transferredBytes"`
+	TotalBytes       int64          `json:"totalBytes"`
+	Warnings         []string       `json:"warnings,omitempty"`
+	Scan             *ScanProgress  `json:"scan,omitempty"` // 扫描进度
 	Error            string         `json:"error,omitempty"`
 	Result           *MigrateResult `json:"result,omitempty"`
+	QueuePosition    int            `json:"queuePosition,omitempty"` // 排队位置 (从 1 开始)
+	RateLimit        int64          `json:"rateLimit,omitempty"`     // 当前上传限速 (字节/秒)
+	Windows          []string       `json:"windows,omitempty"`       // 当前允许传输的时段
 	StartTime        int64          `json:"startTime"`
 	UpdateTime       int64          `json:"updateTime"`
 }
+
+// TaskControl 任务运行中可调整的参数，保存在控制文件中
+type TaskControl struct {
+	RateLimit int64    `json:"rateLimit"`
+	Windows   []string `json:"windows"`
+}
+
+// StorageInfo ZimaOS 存储信息
+type StorageInfo struct {
+	Name     string   `json:"name"`
+	Path     string   `json:"path"`
+	Free     int64    `json:"free"`
+	Used     int64    `json:"used"`
+	Total    int64    `json:"total"`
+	ReadOnly bool     `json:"readOnly"`
+	Folders  []string `json:"folders"` // 顶层目录
+}
+
+// ConnectionCheck 连接测试的单项检查结果
+type ConnectionCheck struct {
+	Name     string `json:"name"` // url/reachability/tls/health/login/capabilities
+	OK       bool   `json:"ok"`
+	Skipped  bool   `json:"skipped,omitempty"`
+	Message  string `json:"message"`
+	Duration int64  `json:"duration"` // 耗时 (毫秒)
+}
+
+// ConnectionReport 连接测试结果
+type ConnectionReport struct {
+	OK           bool              `json:"ok"`
+	Checks       []ConnectionCheck `json:"checks"`
+	Capabilities map[string]bool   `json:"capabilities"`
+	APIProfile   string            `json:"apiProfile,omitempty"` // 识别出的服务端 API 版本: zimaos/casaos
+}
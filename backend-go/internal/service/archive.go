@@ -0,0 +1,86 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// 小文件打包格式
+	ArchiveFormatZip = "zip" // 默认
+	ArchiveFormatTar = "tar"
+)
+
+// ArchiveMember 归档中的一个文件
+type ArchiveMember struct {
+	Name      string // 归档内的文件名
+	LocalPath string // 本地文件路径
+	Size      int64  // 扫描时的大小，读取时大小不同视为文件已变化
+	ModTime   time.Time
+}
+
+// archiveFileMode 归档中文件的权限，与逐个上传一致不保留原权限
+const archiveFileMode = 0644
+
+// WriteArchive 将 members 依次写入 w，zip 格式不压缩 (仅存储)
+func WriteArchive(w io.Writer, format string, members []ArchiveMember) error {
+	switch format {
+	case "", ArchiveFormatZip:
+		zw := zip.NewWriter(w)
+		for _, m := range members {
+			header := &zip.FileHeader{Name: m.Name, Method: zip.Store, Modified: m.ModTime}
+			header.SetMode(archiveFileMode)
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if err := copyMember(fw, m); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	case ArchiveFormatTar:
+		tw := tar.NewWriter(w)
+		for _, m := range members {
+			header := &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     m.Name,
+				Size:     m.Size,
+				Mode:     archiveFileMode,
+				ModTime:  m.ModTime,
+				Format:   tar.FormatPAX,
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if err := copyMember(tw, m); err != nil {
+				return err
+			}
+		}
+		return tw.Close()
+	default:
+		return fmt.Errorf("不支持的打包格式: %s", format)
+	}
+}
+
+// copyMember 写入文件内容，内容长度须与扫描时一致
+func copyMember(w io.Writer, m ArchiveMember) error {
+	f, err := os.Open(m.LocalPath)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+
+	n, err := io.Copy(w, io.LimitReader(f, m.Size))
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+	if extra, _ := f.Read(make([]byte, 1)); n != m.Size || extra > 0 {
+		return fmt.Errorf("文件 %s 在打包过程中发生变化", m.Name)
+	}
+	return nil
+}
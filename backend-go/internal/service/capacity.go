@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// 容量检查策略
+	CapacityCheckStrict = "strict" // 空间不足时拒绝开始 (默认)
+	CapacityCheckWarn   = "warn"   // 空间不足时仅警告
+	CapacityCheckOff    = "off"    // 不检查
+
+	// DefaultReserveBytes 目标存储默认保留空间
+	DefaultReserveBytes int64 = 1 << 30
+
+	// 上传过程中重新查询可用空间的间隔
+	spaceCheckInterval = 30 * time.Second
+	spaceCheckBytes    = 1 << 30
+)
+
+// ErrInsufficientSpace 目标存储空间不足
+var ErrInsufficientSpace = errors.New("目标存储空间不足")
+
+// SpaceWatcher 上传过程中监控目标存储可用空间
+// 按时间或已上传字节数定期刷新可用空间，空间不足时暂停等待。
+// 查询可用空间失败后自动停用，不再阻塞上传
+type SpaceWatcher struct {
+	mu        sync.Mutex
+	disabled  bool
+	freeFn    func() (int64, error)
+	reserve   int64
+	free      int64
+	consumed  int64 // 上次刷新后已上传的字节数
+	lastCheck time.Time
+}
+
+// NewSpaceWatcher 创建空间监控器，freeFn 返回目标存储当前可用空间
+func NewSpaceWatcher(freeFn func() (int64, error), reserve int64) *SpaceWatcher {
+	if reserve <= 0 {
+		reserve = DefaultReserveBytes
+	}
+	return &SpaceWatcher{freeFn: freeFn, reserve: reserve, free: -1}
+}
+
+// CheckTotal 上传前检查可用空间能否容纳 totalBytes
+func (w *SpaceWatcher) CheckTotal(totalBytes int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.refresh(); err != nil {
+		return err
+	}
+	if totalBytes+w.reserve > w.free {
+		return fmt.Errorf("%w: 需要 %s (含保留 %s)，可用 %s", ErrInsufficientSpace,
+			FormatBytes(totalBytes+w.reserve), FormatBytes(w.reserve), FormatBytes(w.free))
+	}
+	return nil
+}
+
+// Wait 在上传 size 字节前确认空间充足，不足时每隔一段时间重新查询，
+// 直到空间足够为止。onPause 在每次等待前被调用
+func (w *SpaceWatcher) Wait(size int64, onPause func(free int64)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.disabled {
+		return nil
+	}
+	if w.free < 0 || w.consumed >= spaceCheckBytes || time.Since(w.lastCheck) >= spaceCheckInterval {
+		if err := w.refresh(); err != nil {
+			return err
+		}
+	}
+
+	for w.free-w.consumed-size < w.reserve {
+		// 估算值不足时先刷新一次，避免因估算误差而暂停
+		if err := w.refresh(); err != nil {
+			return err
+		}
+		if w.free-size >= w.reserve {
+			break
+		}
+		free := w.free
+		w.mu.Unlock()
+		onPause(free)
+		time.Sleep(spaceCheckInterval)
+		w.mu.Lock()
+	}
+	return nil
+}
+
+// Consume 记录已上传的字节数
+func (w *SpaceWatcher) Consume(size int64) {
+	w.mu.Lock()
+	w.consumed += size
+	w.mu.Unlock()
+}
+
+// refresh 重新查询可用空间，失败时停用监控
+func (w *SpaceWatcher) refresh() error {
+	free, err := w.freeFn()
+	if err != nil {
+		w.disabled = true
+		return fmt.Errorf("查询目标存储可用空间失败: %w", err)
+	}
+	w.free = free
+	w.consumed = 0
+	w.lastCheck = time.Now()
+	return nil
+}
+
+// FormatBytes 将字节数格式化为易读的字符串
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// LocalFree 返回本地目录所在存储卷的可用空间
+func LocalFree(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"os"
+)
+
+const (
+	// 源文件在迁移过程中变化时的处理策略
+	// 每个文件上传前后总是与扫描时比较，上传过程中变化的文件重新上传 (warn 模式下只记录)；
+	// 策略决定全部上传后是否重新扫描源目录核对，核对耗时与首次扫描相当，因此默认不核对
+	ChangePolicyReupload = "reupload" // 核对并重新上传上传后被修改和扫描后新增的文件
+	ChangePolicyWarn     = "warn"     // 核对但仅记录变化，不重新上传
+	ChangePolicyOff      = "off"      // 不核对 (默认)
+
+	// 源文件变化类型
+	ChangeModified = "modified" // 扫描后被修改
+	ChangeUnstable = "unstable" // 上传过程中反复被修改，目标端文件可能不完整
+	ChangeAdded    = "added"    // 扫描后新增
+	ChangeRemoved  = "removed"  // 扫描后被删除
+)
+
+// FileChanged 判断文件当前状态与扫描时记录的大小、修改时间是否不同
+func FileChanged(entry ScanEntry, info os.FileInfo) bool {
+	return info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime)
+}
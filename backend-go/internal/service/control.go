@@ -0,0 +1,53 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ftoz/internal/model"
+)
+
+// controlFilePath 返回任务控制文件路径，worker 运行时定期读取
+func controlFilePath(taskId string) string {
+	return filepath.Join(StatusDir, fmt.Sprintf("ftoz-control-%s.json", taskId))
+}
+
+// WriteTaskControl 写入任务控制文件
+func WriteTaskControl(taskId string, control *model.TaskControl) error {
+	data, err := json.Marshal(control)
+	if err != nil {
+		return err
+	}
+
+	controlFile := controlFilePath(taskId)
+	tmpFile := controlFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, controlFile)
+}
+
+// ReadTaskControl 读取任务控制文件，同时返回文件修改时间用于判断是否有变化
+func ReadTaskControl(taskId string) (*model.TaskControl, int64, error) {
+	controlFile := controlFilePath(taskId)
+	info, err := os.Stat(controlFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := os.ReadFile(controlFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	var control model.TaskControl
+	if err := json.Unmarshal(data, &control); err != nil {
+		return nil, 0, err
+	}
+	return &control, info.ModTime().UnixNano(), nil
+}
+
+// RemoveTaskControl 删除任务控制文件
+func RemoveTaskControl(taskId string) {
+	os.Remove(controlFilePath(taskId))
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"ftoz/internal/model"
+)
+
+// DedupMinSize 参与去重的最小文件大小，更小的文件直接上传比服务端复制更快
+const DedupMinSize = 1 << 20
+
+// DuplicateSet 内容相同的一组文件，Original 上传，Copies 在目标端由 Original 复制
+type DuplicateSet struct {
+	Hash     string
+	Original ScanEntry
+	Copies   []ScanEntry
+}
+
+// Group 转换为结果中的重复文件组
+func (d *DuplicateSet) Group() model.DuplicateGroup {
+	group := model.DuplicateGroup{
+		Hash: d.Hash,
+		Size: d.Original.Size,
+		Path: filepath.ToSlash(d.Original.DstPath),
+	}
+	for _, c := range d.Copies {
+		group.Copies = append(group.Copies, filepath.ToSlash(c.DstPath))
+	}
+	return group
+}
+
+// FindDuplicates 查找内容相同的文件
+// 先按大小分组，只计算存在同样大小文件的 SHA-256；返回去掉重复副本后的文件列表和重复文件组。
+// 无法读取的文件视为不重复，由上传阶段处理
+func FindDuplicates(sourceDir string, files []ScanEntry, onProgress func(hashed, total int)) ([]ScanEntry, []DuplicateSet) {
+	bySize := make(map[int64][]int)
+	for i, f := range files {
+		if f.Size >= DedupMinSize {
+			bySize[f.Size] = append(bySize[f.Size], i)
+		}
+	}
+	var candidates []int
+	for _, idx := range bySize {
+		if len(idx) > 1 {
+			candidates = append(candidates, idx...)
+		}
+	}
+	if len(candidates) == 0 {
+		return files, nil
+	}
+
+	// 并发计算哈希
+	hashes := make([]string, len(files))
+	jobs := make(chan int)
+	var (
+		wg     sync.WaitGroup
+		hashed atomic.Int64
+	)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hashes[i] = hashFile(filepath.Join(sourceDir, files[i].Path))
+				n := hashed.Add(1)
+				if onProgress != nil {
+					onProgress(int(n), len(candidates))
+				}
+			}
+		}()
+	}
+	for _, i := range candidates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// 按大小和哈希分组，同组中目标路径最小的文件作为原件
+	type key struct {
+		size int64
+		hash string
+	}
+	groups := make(map[key][]int)
+	for _, i := range candidates {
+		if hashes[i] != "" {
+			k := key{files[i].Size, hashes[i]}
+			groups[k] = append(groups[k], i)
+		}
+	}
+
+	duplicate := make(map[int]bool)
+	var sets []DuplicateSet
+	for k, idx := range groups {
+		if len(idx) < 2 {
+			continue
+		}
+		sort.Slice(idx, func(a, b int) bool { return files[idx[a]].DstPath < files[idx[b]].DstPath })
+		set := DuplicateSet{Hash: k.hash, Original: files[idx[0]]}
+		for _, i := range idx[1:] {
+			set.Copies = append(set.Copies, files[i])
+			duplicate[i] = true
+		}
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(a, b int) bool { return sets[a].Original.DstPath < sets[b].Original.DstPath })
+
+	unique := make([]ScanEntry, 0, len(files)-len(duplicate))
+	for i, f := range files {
+		if !duplicate[i] {
+			unique = append(unique, f)
+		}
+	}
+	return unique, sets
+}
+
+// hashFile 计算文件的 SHA-256，失败时返回空字符串
+func hashFile(p string) string {
+	f, err := os.Open(p)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
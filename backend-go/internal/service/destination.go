@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// 目标端类型
+	DestTypeZimaOS = "zimaos" // ZimaOS (默认)
+	DestTypeLocal  = "local"  // 本地目录，如 USB 硬盘或其他挂载路径
+)
+
+// ErrNotFound 目标端路径不存在
+var ErrNotFound = errors.New("目标路径不存在")
+
+// ErrDirTimeUnsupported 目标端不支持设置目录修改时间
+var ErrDirTimeUnsupported = errors.New("目标端不支持设置目录修改时间")
+
+// DestEntry 目标端的文件或目录
+type DestEntry struct {
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+
+	Uploaded bool // ModTime 为上传时间而非原修改时间 (对象存储的列表结果)
+}
+
+// Destination 迁移目标端
+// 路径均为相对目标根目录、以 / 分隔的路径，根目录为 ""
+type Destination interface {
+	// Authenticate 登录或校验目标端，须在其他操作之前调用
+	Authenticate() error
+	// Mkdir 创建目录 (父目录已存在)，目录已存在时不报错
+	Mkdir(relDir string) error
+	// PutFile 上传本地文件，保留修改时间
+	PutFile(relPath, localPath string) error
+	// Stat 查询文件或目录，不存在时返回 ErrNotFound
+	Stat(relPath string) (*DestEntry, error)
+	// List 列出目录下的文件和子目录
+	List(relDir string) ([]DestEntry, error)
+}
+
+// FreeSpacer 可查询可用空间的目标端
+type FreeSpacer interface {
+	Free() (int64, error)
+}
+
+// Extractor 可在服务端解压归档的目标端，用于小文件打包上传
+type Extractor interface {
+	// Extract 将归档 relArchive 解压到 relDir 目录，同名文件覆盖
+	Extract(relArchive, relDir string) error
+	// Remove 删除文件
+	Remove(relPath string) error
+}
+
+// Copier 可在服务端复制文件的目标端，用于去重
+type Copier interface {
+	// Copy 将已上传的 relSrc 复制为 relDst，relDst 所在目录已存在
+	Copy(relSrc, relDst string) error
+	// CleanupCopies 删除复制过程中创建的临时文件，全部复制结束后调用
+	CleanupCopies() error
+}
+
+// DirTimer 可设置目录修改时间的目标端
+// 目录内容全部上传后调用，否则上传文件会再次改变目录时间
+type DirTimer interface {
+	// SetDirTime 设置目录修改时间，目标端不支持时返回 ErrDirTimeUnsupported
+	SetDirTime(relDir string, mtime time.Time) error
+}
+
+var (
+	_ Destination = (*ZimaOSClient)(nil)
+	_ Destination = (*LocalDestination)(nil)
+	_ Destination = (*WebDAVDestination)(nil)
+	_ Destination = (*S3Destination)(nil)
+	_ Destination = (*SFTPDestination)(nil)
+	_ FreeSpacer  = (*ZimaOSClient)(nil)
+	_ FreeSpacer  = (*LocalDestination)(nil)
+	_ FreeSpacer  = (*WebDAVDestination)(nil)
+	_ FreeSpacer  = (*SFTPDestination)(nil)
+	_ Extractor   = (*ZimaOSClient)(nil)
+	_ Copier      = (*ZimaOSClient)(nil)
+	_ DirTimer    = (*ZimaOSClient)(nil)
+	_ DirTimer    = (*LocalDestination)(nil)
+	_ DirTimer    = (*WebDAVDestination)(nil)
+	_ DirTimer    = (*SFTPDestination)(nil)
+)
+
+// statByList 通过列出父目录实现 Stat
+func statByList(dest Destination, relPath string) (*DestEntry, error) {
+	dir, name := path.Split(relPath)
+	entries, err := dest.List(path.Clean("/" + dir)[1:])
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return &e, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// LocalDestination 本地目录目标端
+// 文件先写入同目录下的临时文件，完成后设置修改时间再改名，避免留下不完整的文件
+type LocalDestination struct {
+	root string
+}
+
+// NewLocalDestination 创建本地目录目标端
+func NewLocalDestination(root string) *LocalDestination {
+	return &LocalDestination{root: filepath.Clean(root)}
+}
+
+// Authenticate 创建根目录并确认可写
+func (d *LocalDestination) Authenticate() error {
+	if !filepath.IsAbs(d.root) {
+		return fmt.Errorf("目标目录须为绝对路径: %s", d.root)
+	}
+	if err := os.MkdirAll(d.root, 0755); err != nil {
+		return fmt.Errorf("创建目标目录失败: %w", err)
+	}
+	probe, err := os.CreateTemp(d.root, ".ftoz-*.probe")
+	if err != nil {
+		return fmt.Errorf("目标目录不可写: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// Mkdir 创建目录
+func (d *LocalDestination) Mkdir(relDir string) error {
+	err := os.Mkdir(d.path(relDir), 0755)
+	if err != nil && !os.IsExist(err) {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	return nil
+}
+
+// SetDirTime 设置目录修改时间
+func (d *LocalDestination) SetDirTime(relDir string, mtime time.Time) error {
+	return os.Chtimes(d.path(relDir), mtime, mtime)
+}
+
+// PutFile 复制文件到目标目录
+func (d *LocalDestination) PutFile(relPath, localPath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("获取文件信息失败: %w", err)
+	}
+
+	dstPath := d.path(relPath)
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), ".ftoz-*.part")
+	if err != nil {
+		return fmt.Errorf("创建目标文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, limitUpload(src))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入文件内容失败: %w", err)
+	}
+
+	os.Chmod(tmp.Name(), stat.Mode().Perm())
+	if err := os.Chtimes(tmp.Name(), stat.ModTime(), stat.ModTime()); err != nil {
+		return fmt.Errorf("设置修改时间失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dstPath); err != nil {
+		return fmt.Errorf("保存文件失败: %w", err)
+	}
+	return nil
+}
+
+// Stat 查询文件或目录
+func (d *LocalDestination) Stat(relPath string) (*DestEntry, error) {
+	info, err := os.Stat(d.path(relPath))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DestEntry{Name: info.Name(), IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List 列出目录
+func (d *LocalDestination) List(relDir string) ([]DestEntry, error) {
+	entries, err := os.ReadDir(d.path(relDir))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]DestEntry, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		result = append(result, DestEntry{Name: e.Name(), IsDir: e.IsDir(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return result, nil
+}
+
+// Free 返回目标目录所在存储卷的可用空间
+func (d *LocalDestination) Free() (int64, error) {
+	return LocalFree(d.root)
+}
+
+func (d *LocalDestination) path(relPath string) string {
+	return filepath.Join(d.root, filepath.FromSlash(relPath))
+}
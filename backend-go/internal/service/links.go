@@ -0,0 +1,58 @@
+package service
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+
+	"ftoz/internal/model"
+)
+
+const (
+	// 符号链接处理策略
+	LinkPolicySkip     = "skip"     // 跳过并记录 (默认)
+	LinkPolicyFollow   = "follow"   // 跟随链接，上传目标内容
+	LinkPolicyPreserve = "preserve" // 不上传，记录到目标端的链接清单
+
+	// LinkManifestName 链接清单文件名，上传到目标目录根部
+	LinkManifestName = ".ftoz-links.json"
+)
+
+// LinkManifest 链接清单，用于在目标端还原符号链接和硬链接
+type LinkManifest struct {
+	Version   int                   `json:"version"`
+	Source    string                `json:"source"`
+	Symlinks  []model.LinkRecord    `json:"symlinks"`
+	HardLinks []model.HardLinkGroup `json:"hardLinks"`
+}
+
+// fileID 文件在本机上的唯一标识
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// statID 返回文件的设备号、inode 和硬链接数
+func statID(info os.FileInfo) (fileID, uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, 0, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// specialType 返回特殊文件的类型名称
+func specialType(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode&fs.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	default:
+		return "other"
+	}
+}
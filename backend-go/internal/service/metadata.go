@@ -0,0 +1,167 @@
+package service
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// MetadataSidecarName 元数据清单文件名，上传到目标目录根部
+const MetadataSidecarName = ".ftoz-metadata.json"
+
+// MetadataSidecar 元数据清单，记录目标端无法保存的权限、属主、扩展属性和链接目标，用于日后还原
+type MetadataSidecar struct {
+	Version int            `json:"version"`
+	Source  string         `json:"source"`
+	Created int64          `json:"created"`
+	Entries []PathMetadata `json:"entries"`
+}
+
+// PathMetadata 单个路径的元数据
+type PathMetadata struct {
+	Path    string            `json:"path"`             // 目标端相对路径
+	Source  string            `json:"source,omitempty"` // 源相对路径，与目标端路径不同时记录
+	Type    string            `json:"type"`             // file/dir/symlink
+	Mode    string            `json:"mode,omitempty"`   // 权限 (八进制，含 setuid/setgid/sticky)
+	UID     int               `json:"uid"`
+	GID     int               `json:"gid"`
+	Owner   string            `json:"owner,omitempty"`
+	Group   string            `json:"group,omitempty"`
+	ModTime int64             `json:"mtime,omitempty"`  // 修改时间 (Unix 秒)
+	Xattrs  map[string][]byte `json:"xattrs,omitempty"` // 扩展属性 (含 POSIX ACL: system.posix_acl_access)，值为 base64
+	Target  string            `json:"target,omitempty"` // 符号链接目标
+	Error   string            `json:"error,omitempty"`  // 读取失败的原因
+}
+
+// MetadataReader 读取源文件元数据，缓存用户名和组名
+type MetadataReader struct {
+	sourceDir string
+
+	mu     sync.Mutex
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+// NewMetadataReader 创建元数据读取器
+func NewMetadataReader(sourceDir string) *MetadataReader {
+	return &MetadataReader{
+		sourceDir: sourceDir,
+		users:     make(map[uint32]string),
+		groups:    make(map[uint32]string),
+	}
+}
+
+// Read 读取源相对路径 relPath 的元数据，dstPath 为目标端相对路径
+// 读取失败时在 Error 中记录原因，不中断清单生成
+func (r *MetadataReader) Read(relPath, dstPath string) PathMetadata {
+	meta := PathMetadata{Path: filepath.ToSlash(dstPath)}
+	if src := filepath.ToSlash(relPath); src != meta.Path {
+		meta.Source = src
+	}
+
+	fullPath := filepath.Join(r.sourceDir, relPath)
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		meta.Error = err.Error()
+		return meta
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		meta.Type = "symlink"
+		meta.Target, _ = os.Readlink(fullPath)
+	case info.IsDir():
+		meta.Type = "dir"
+	default:
+		meta.Type = "file"
+	}
+	meta.ModTime = info.ModTime().Unix()
+
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		meta.Mode = "0" + strconv.FormatUint(uint64(st.Mode&07777), 8)
+		meta.UID = int(st.Uid)
+		meta.GID = int(st.Gid)
+		meta.Owner = r.userName(st.Uid)
+		meta.Group = r.groupName(st.Gid)
+	}
+
+	// 符号链接的扩展属性读取的是链接目标，不记录
+	if meta.Type != "symlink" {
+		xattrs, err := readXattrs(fullPath)
+		if err != nil {
+			meta.Error = "读取扩展属性失败: " + err.Error()
+		}
+		if len(xattrs) > 0 {
+			meta.Xattrs = xattrs
+		}
+	}
+	return meta
+}
+
+func (r *MetadataReader) userName(uid uint32) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name, ok := r.users[uid]
+	if !ok {
+		if u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+			name = u.Username
+		}
+		r.users[uid] = name
+	}
+	return name
+}
+
+func (r *MetadataReader) groupName(gid uint32) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name, ok := r.groups[gid]
+	if !ok {
+		if g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10)); err == nil {
+			name = g.Name
+		}
+		r.groups[gid] = name
+	}
+	return name
+}
+
+// readXattrs 读取全部扩展属性，文件系统不支持扩展属性时返回空
+func readXattrs(p string) (map[string][]byte, error) {
+	size, err := syscall.Listxattr(p, nil)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(p, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string][]byte)
+	for _, name := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		n, err := syscall.Getxattr(p, name, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		if n > 0 {
+			if n, err = syscall.Getxattr(p, name, value); err != nil {
+				continue
+			}
+		}
+		xattrs[name] = value[:n]
+	}
+	return xattrs, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+
+	"ftoz/internal/model"
+	"ftoz/internal/util"
+)
+
+const (
+	// 文件名编码
+	NameCharsetAuto = "auto" // 自动识别 GBK/Big5 (默认)
+	NameCharsetNone = "none" // 不转码，按原始字节上传
+
+	// Unicode 规范化
+	NormalizeNFC  = "nfc"  // 转换为 NFC (默认)
+	NormalizeNone = "none" // 保持原样
+
+	// 重名处理策略
+	CollisionRename = "rename" // 后出现的条目改名为 "名称 (1)" (默认)
+	CollisionSkip   = "skip"   // 跳过后出现的条目
+	CollisionFail   = "fail"   // 扫描失败
+)
+
+// ErrNameCollision 目标端文件名冲突
+var ErrNameCollision = errors.New("目标端文件名冲突")
+
+// nameMapper 将源文件名转换为目标端文件名
+type nameMapper struct {
+	charset     string
+	normalize   string
+	onCollision string
+}
+
+func newNameMapper(opts ScanOptions) *nameMapper {
+	m := &nameMapper{
+		charset:     opts.NameCharset,
+		normalize:   opts.Normalize,
+		onCollision: opts.OnCollision,
+	}
+	if m.onCollision == "" {
+		m.onCollision = CollisionRename
+	}
+	return m
+}
+
+// plannedName 目录条目在目标端的名称
+type plannedName struct {
+	dst    string
+	reason string // 改名原因，未改名时为空
+	skip   string // 非空时跳过该条目，值为跳过原因
+}
+
+// target 返回目标端文件名及改名原因，无需改名时原因为空
+func (m *nameMapper) target(name string) (string, string) {
+	var reasons []string
+	if !utf8.ValidString(name) && m.charset != NameCharsetNone {
+		var reason string
+		name, reason = m.decode(name)
+		reasons = append(reasons, reason)
+	}
+	if m.normalize != NormalizeNone && utf8.ValidString(name) && !norm.NFC.IsNormalString(name) {
+		name = norm.NFC.String(name)
+		reasons = append(reasons, NormalizeNFC)
+	}
+	return name, strings.Join(reasons, ",")
+}
+
+// decode 将非 UTF-8 文件名转换为 UTF-8，返回新名称及改名原因
+func (m *nameMapper) decode(name string) (string, string) {
+	charset := m.charset
+	if charset == "" || charset == NameCharsetAuto {
+		charset = util.DetectCharset([]byte(name))
+	}
+	if charset != "" {
+		if decoded, err := util.DecodeString([]byte(name), charset); err == nil {
+			return decoded, "charset:" + strings.ToLower(charset)
+		}
+	}
+	// 无法识别编码时替换无效字节，避免上传失败
+	return strings.ToValidUTF8(name, "_"), "charset:invalid"
+}
+
+// plan 计算目录中各条目的目标端名称，并检测规范化及忽略大小写后的重名
+// active 返回 false 的条目 (不会上传) 不参与重名检测
+func (m *nameMapper) plan(dstDir string, entries []fs.DirEntry, active func(fs.DirEntry) bool) ([]plannedName, []model.NameCollision, error) {
+	fold := cases.Fold()
+	key := func(name string) string {
+		return collisionKey(fold, name)
+	}
+	planned := make([]plannedName, len(entries))
+	groups := make(map[string][]int)
+	var order []string
+
+	for i, entry := range entries {
+		dst, reason := m.target(entry.Name())
+		planned[i] = plannedName{dst: dst, reason: reason}
+		if !active(entry) {
+			continue
+		}
+		k := key(dst)
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	var collisions []model.NameCollision
+	for _, k := range order {
+		idx := groups[k]
+		if len(idx) < 2 {
+			continue
+		}
+
+		// 按目录读取顺序保留第一个条目的名称
+		collision := model.NameCollision{Dir: filepath.ToSlash(dstDir), Policy: m.onCollision}
+		for _, i := range idx {
+			collision.Names = append(collision.Names, util.EscapeInvalidUTF8(entries[i].Name()))
+		}
+		collisions = append(collisions, collision)
+
+		kept := planned[idx[0]].dst
+		switch m.onCollision {
+		case CollisionFail:
+			return nil, collisions, fmt.Errorf("%w: %s", ErrNameCollision, path.Join(collision.Dir, strings.Join(collision.Names, " / ")))
+		case CollisionSkip:
+			for _, i := range idx[1:] {
+				planned[i].skip = fmt.Sprintf("与 %s 在目标端重名", kept)
+			}
+		default:
+			for _, i := range idx[1:] {
+				planned[i].dst = uniqueName(planned[i].dst, entries[i].IsDir(), func(name string) bool {
+					_, taken := groups[key(name)]
+					return taken
+				})
+				groups[key(planned[i].dst)] = []int{i}
+				if planned[i].reason != "" {
+					planned[i].reason += ","
+				}
+				planned[i].reason += "collision"
+			}
+		}
+	}
+	return planned, collisions, nil
+}
+
+// collisionKey 重名检测使用的名称：NFC 规范化后忽略大小写
+func collisionKey(fold cases.Caser, name string) string {
+	return fold.String(norm.NFC.String(name))
+}
+
+// uniqueName 在名称后追加序号 (文件追加在扩展名前)，直到不与已有名称冲突
+func uniqueName(name string, isDir bool, taken func(string) bool) string {
+	base, ext := name, ""
+	if !isDir {
+		if e := path.Ext(name); e != "" && e != name {
+			base, ext = strings.TrimSuffix(name, e), e
+		}
+	}
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+
+	"ftoz/internal/config"
+	"ftoz/internal/model"
+	"ftoz/internal/util"
+)
+
+const (
+	// 目标端路径校验模式
+	PathCheckSanitize = "sanitize" // 自动修正并记录对应关系 (默认)
+	PathCheckReport   = "report"   // 列出不符合规则的路径，上传前失败
+	PathCheckOff      = "off"      // 不校验
+
+	// 路径规则
+	RuleIllegalChar = "illegal-char"
+	RuleTrailing    = "trailing"
+	RuleNameLength  = "name-length"
+	RulePathLength  = "path-length"
+)
+
+// ErrInvalidPaths 存在不符合目标端路径规则的路径
+var ErrInvalidPaths = errors.New("存在目标端不支持的路径")
+
+// PathValidator 按目标端路径规则校验扫描条目
+// 条目须按父目录先于子条目的顺序传入，目录改名后其子条目的目标路径随之调整
+type PathValidator struct {
+	rules      config.PathRules
+	mode       string
+	remoteRoot string
+
+	paths   map[string]string          // 目标路径已调整的条目: 原路径 -> 新路径
+	skipped map[string]bool            // 无法修正而跳过的目录
+	names   map[string]map[string]bool // 目标目录 -> 已使用的名称 (忽略大小写)，用于检测修正后的重名
+	fold    cases.Caser
+
+	Renamed        []model.PathMapping
+	Violations     []model.PathViolation // 最多保留 maxScanErrors 条
+	ViolationCount int
+	SkippedFiles   int
+	SkippedBytes   int64
+}
+
+// NewPathValidator 创建路径校验器，remoteRoot 为目标目录的完整远程路径 (计入路径长度)
+func NewPathValidator(rules config.PathRules, mode, remoteRoot string) *PathValidator {
+	if mode == "" {
+		mode = PathCheckSanitize
+	}
+	return &PathValidator{
+		rules:      rules,
+		mode:       mode,
+		remoteRoot: strings.TrimRight(remoteRoot, "/"),
+		paths:      make(map[string]string),
+		skipped:    make(map[string]bool),
+		names:      make(map[string]map[string]bool),
+		fold:       cases.Fold(),
+	}
+}
+
+// Check 校验条目并按模式处理，sanitize 模式下修正 entry.DstPath
+// 返回 false 表示跳过该条目 (无法修正的路径及其子条目)
+func (v *PathValidator) Check(entry *ScanEntry) bool {
+	dir, name := filepath.Split(entry.DstPath)
+	dir = strings.TrimSuffix(dir, string(filepath.Separator))
+	if v.skipped[dir] {
+		v.skip(entry)
+		return false
+	}
+	if mapped, ok := v.paths[dir]; ok {
+		dir = mapped
+	}
+
+	fixed, rules := v.checkName(name)
+	dstPath := filepath.Join(dir, name)
+	collided := false
+	if v.mode == PathCheckSanitize {
+		// 修正后的名称可能与同目录的其他条目重名 (如 a:b 与 a_b、foo. 与 foo)，重名时追加序号
+		unique := v.claim(dir, fixed, entry.IsDir)
+		collided = unique != fixed
+		fixed = unique
+		dstPath = filepath.Join(dir, fixed)
+	}
+
+	if len(rules) > 0 || collided {
+		if v.mode == PathCheckReport {
+			v.violate(entry, strings.Join(rules, ","), fmt.Sprintf("文件名 %q 不符合目标端规则", name))
+		} else {
+			var reasons []string
+			if len(rules) > 0 {
+				reasons = append(reasons, "sanitize:"+strings.Join(rules, ","))
+			}
+			if collided {
+				reasons = append(reasons, "collision")
+			}
+			v.Renamed = append(v.Renamed, model.PathMapping{
+				Path:    util.EscapeInvalidUTF8(filepath.ToSlash(entry.Path)),
+				DstPath: filepath.ToSlash(dstPath),
+				Reason:  strings.Join(reasons, ","),
+			})
+		}
+	}
+
+	// 完整路径过长无法自动修正
+	remotePath := v.remoteRoot + "/" + filepath.ToSlash(dstPath)
+	if len(remotePath) > v.rules.MaxPathBytes {
+		v.violate(entry, RulePathLength, fmt.Sprintf("远程路径 %d 字节，超过 %d 字节", len(remotePath), v.rules.MaxPathBytes))
+		if v.mode == PathCheckSanitize {
+			v.skip(entry)
+			return false
+		}
+	}
+
+	if dstPath != entry.DstPath {
+		v.paths[entry.DstPath] = dstPath
+	}
+	entry.DstPath = dstPath
+	return true
+}
+
+// Resolve 返回校验前的目标路径调整后的结果，用于链接清单等记录
+func (v *PathValidator) Resolve(dstPath string) string {
+	if mapped, ok := v.paths[dstPath]; ok {
+		return mapped
+	}
+	dir := filepath.Dir(dstPath)
+	if dir == "." || dir == dstPath {
+		return dstPath
+	}
+	return filepath.Join(v.Resolve(dir), filepath.Base(dstPath))
+}
+
+// Err report 模式下存在违规路径时返回错误
+func (v *PathValidator) Err() error {
+	if v.mode != PathCheckReport || v.ViolationCount == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: 共 %d 处，例如 %s (%s)", ErrInvalidPaths, v.ViolationCount, v.Violations[0].DstPath, v.Violations[0].Detail)
+}
+
+// claim 在目标目录 dir 中占用名称，与已占用的名称重名时返回追加序号后的名称
+// 追加序号后超过文件名长度限制时先截断原名称
+func (v *PathValidator) claim(dir, name string, isDir bool) string {
+	used := v.names[dir]
+	if used == nil {
+		used = make(map[string]bool)
+		v.names[dir] = used
+	}
+	taken := func(n string) bool { return used[collisionKey(v.fold, n)] }
+
+	if taken(name) {
+		unique := uniqueName(name, isDir, taken)
+		if len(unique) > v.rules.MaxNameBytes {
+			base := truncateName(name, v.rules.MaxNameBytes-(len(unique)-len(name)))
+			unique = uniqueName(base, isDir, taken)
+		}
+		name = unique
+	}
+	used[collisionKey(v.fold, name)] = true
+	return name
+}
+
+// checkName 检查文件名，返回修正后的名称及违反的规则
+func (v *PathValidator) checkName(name string) (string, []string) {
+	var rules []string
+
+	fixed := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(v.rules.IllegalChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if fixed != name {
+		rules = append(rules, RuleIllegalChar)
+	}
+
+	if len(fixed) > v.rules.MaxNameBytes {
+		rules = append(rules, RuleNameLength)
+		fixed = truncateName(fixed, v.rules.MaxNameBytes)
+	}
+
+	// 结尾的空格和点号在 Samba 共享中无法访问
+	if trimmed := strings.TrimRight(fixed, " ."); trimmed != fixed {
+		rules = append(rules, RuleTrailing)
+		fixed = trimmed
+		if fixed == "" {
+			fixed = "_"
+		}
+	}
+	return fixed, rules
+}
+
+func (v *PathValidator) violate(entry *ScanEntry, rule, detail string) {
+	v.ViolationCount++
+	if len(v.Violations) < maxScanErrors {
+		v.Violations = append(v.Violations, model.PathViolation{
+			Path:    util.EscapeInvalidUTF8(filepath.ToSlash(entry.Path)),
+			DstPath: filepath.ToSlash(entry.DstPath),
+			Rule:    rule,
+			Detail:  detail,
+		})
+	}
+}
+
+func (v *PathValidator) skip(entry *ScanEntry) {
+	if entry.IsDir {
+		v.skipped[entry.DstPath] = true
+		return
+	}
+	v.SkippedFiles++
+	v.SkippedBytes += entry.Size
+}
+
+// truncateName 将文件名截断到 max 字节以内，保留扩展名且不截断多字节字符
+func truncateName(name string, max int) string {
+	ext := path.Ext(name)
+	if ext == name || len(ext) >= max/2 {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	limit := max - len(ext)
+	for len(base) > limit {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	return base + ext
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+
+	"ftoz/internal/model"
+)
+
+const (
+	// 连接测试项
+	CheckURL          = "url"
+	CheckReachability = "reachability"
+	CheckTLS          = "tls"
+	CheckAPI          = "api"
+	CheckHealth       = "health"
+	CheckLogin        = "login"
+	CheckCapabilities = "capabilities"
+
+	// 服务端 API 能力 (路径见 apiProfile)
+	CapabilityMkdir       = "mkdir"       // ZimaOS: POST /v2_1/files/folder
+	CapabilityUploadV2    = "uploadV2"    // ZimaOS: POST /v2_1/files/file/uploadV2
+	CapabilityChunkUpload = "chunkUpload" // ZimaOS: GET/POST /v2_1/files/file/upload
+	CapabilityListFiles   = "listFiles"   // ZimaOS: GET /v2_1/files/file
+	CapabilityTasks       = "tasks"       // ZimaOS: GET /v2_1/files/tasks (服务端导入、解压、复制)
+
+	probeTimeout = 5 * time.Second
+)
+
+// TestConnection 依次检查地址、连通性、TLS、API 版本、健康状态、登录和 API 能力
+// 关键检查 (地址/连通性/TLS/登录) 失败时后续检查标记为跳过
+// 传输层配置了代理时连通性和 TLS 检查经代理进行
+func (c *ZimaOSClient) TestConnection(baseURL, username, password string) *model.ConnectionReport {
+	report := &model.ConnectionReport{
+		OK:           true,
+		Checks:       []model.ConnectionCheck{},
+		Capabilities: map[string]bool{},
+	}
+	failed := false
+
+	run := func(name string, critical bool, fn func() (string, error)) {
+		check := model.ConnectionCheck{Name: name}
+		if failed {
+			check.Skipped = true
+			check.Message = "前置检查未通过，已跳过"
+			report.Checks = append(report.Checks, check)
+			return
+		}
+
+		start := time.Now()
+		msg, err := fn()
+		check.Duration = time.Since(start).Milliseconds()
+		if err != nil {
+			check.Message = err.Error()
+			report.OK = false
+			failed = critical
+		} else {
+			check.OK = true
+			check.Message = msg
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	// 1. 地址格式
+	var u *url.URL
+	run(CheckURL, true, func() (string, error) {
+		var err error
+		u, err = url.Parse(baseURL)
+		if err != nil {
+			return "", fmt.Errorf("地址格式错误: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return "", fmt.Errorf("地址必须以 http:// 或 https:// 开头")
+		}
+		if u.Host == "" {
+			return "", fmt.Errorf("地址缺少主机名")
+		}
+		return u.Scheme + "://" + u.Host, nil
+	})
+
+	// 配置了代理时无法直接连接目标，改为经传输层发送 HEAD 请求，从请求过程判断连通性和 TLS 握手结果
+	var viaProxy *proxyProbe
+	if u != nil {
+		if proxy := c.proxyFor(u); proxy != nil {
+			viaProxy = c.probeViaProxy(u, proxy)
+		}
+	}
+
+	// 2. TCP 连通性
+	run(CheckReachability, true, func() (string, error) {
+		if viaProxy != nil {
+			return viaProxy.reachability(u)
+		}
+		conn, err := net.DialTimeout("tcp", hostPort(u), probeTimeout)
+		if err != nil {
+			return "", fmt.Errorf("无法连接 %s: %w", hostPort(u), err)
+		}
+		conn.Close()
+		return "已连接 " + hostPort(u), nil
+	})
+
+	// 3. TLS 握手 (仅 https)
+	if u != nil && u.Scheme == "https" {
+		run(CheckTLS, true, func() (string, error) {
+			if viaProxy != nil {
+				return viaProxy.handshake()
+			}
+			dialer := &net.Dialer{Timeout: probeTimeout}
+			// 沿用传输层的 CA、指纹等设置
+			tlsConfig := &tls.Config{}
+			if t, ok := c.client.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+				tlsConfig = t.TLSClientConfig.Clone()
+			}
+			tlsConfig.ServerName = u.Hostname()
+			conn, err := tls.DialWithDialer(dialer, "tcp", hostPort(u), tlsConfig)
+			if err != nil {
+				return "", fmt.Errorf("TLS 握手失败: %w", err)
+			}
+			defer conn.Close()
+			return certSummary(conn.ConnectionState().PeerCertificates[0]), nil
+		})
+	}
+
+	// 4. API 版本
+	var profile *apiProfile
+	run(CheckAPI, true, func() (string, error) {
+		var err error
+		profile, err = c.api(baseURL)
+		if err != nil {
+			return "", err
+		}
+		report.APIProfile = profile.Name
+		return profile.Label, nil
+	})
+
+	// 5. 健康检查
+	run(CheckHealth, false, func() (string, error) {
+		if profile.Health == "" {
+			return profile.Label + " 未提供健康检查接口，已跳过", nil
+		}
+		resp, err := c.client.Get(baseURL + profile.Health)
+		if err != nil {
+			return "", fmt.Errorf("健康检查请求失败: %w", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("健康检查失败(%d): %s", resp.StatusCode, string(body))
+		}
+		return "文件服务运行正常", nil
+	})
+
+	// 6. 登录
+	var token string
+	run(CheckLogin, true, func() (string, error) {
+		var err error
+		token, err = c.Login(baseURL, username, password)
+		if err != nil {
+			return "", err
+		}
+		return "登录成功", nil
+	})
+
+	// 7. API 能力
+	run(CheckCapabilities, false, func() (string, error) {
+		report.Capabilities = c.ProbeCapabilities(baseURL, token)
+		if !report.Capabilities[CapabilityUploadV2] {
+			return "", fmt.Errorf("服务端不支持上传接口 %s", profile.Upload)
+		}
+		return fmt.Sprintf("mkdir=%t, chunkUpload=%t, tasks=%t", report.Capabilities[CapabilityMkdir], report.Capabilities[CapabilityChunkUpload], report.Capabilities[CapabilityTasks]), nil
+	})
+
+	return report
+}
+
+// proxyFor 返回传输层访问 u 时使用的代理，不使用代理时返回 nil
+func (c *ZimaOSClient) proxyFor(u *url.URL) *url.URL {
+	rt := c.client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok || t.Proxy == nil {
+		return nil
+	}
+	proxy, err := t.Proxy(&http.Request{Method: "HEAD", URL: u, Header: http.Header{}})
+	if err != nil {
+		return nil
+	}
+	return proxy
+}
+
+// proxyProbe 经代理发送 HEAD 请求的结果
+type proxyProbe struct {
+	proxy *url.URL
+
+	mu        sync.Mutex
+	connected bool // 已经代理连接到目标 (https 时已开始 TLS 握手)
+	tlsState  *tls.ConnectionState
+	tlsErr    error
+	status    int
+	err       error
+}
+
+// probeViaProxy 经传输层 (代理、CA、指纹等设置) 向 u 发送 HEAD 请求
+func (c *ZimaOSClient) probeViaProxy(u *url.URL, proxy *url.URL) *proxyProbe {
+	p := &proxyProbe{proxy: proxy}
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			p.mu.Lock()
+			p.connected = true
+			p.mu.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			p.mu.Lock()
+			p.tlsState, p.tlsErr = &state, err
+			p.mu.Unlock()
+		},
+	}
+	ctx, cancel := context.WithTimeout(httptrace.WithClientTrace(context.Background(), trace), probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", u.Scheme+"://"+u.Host+"/", nil)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	resp, err := c.client.Do(req)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.err = err
+		return p
+	}
+	resp.Body.Close()
+	p.status = resp.StatusCode
+	// 代理无法连接 http 目标时返回 502/504
+	if u.Scheme == "https" || (resp.StatusCode != http.StatusBadGateway && resp.StatusCode != http.StatusGatewayTimeout) {
+		p.connected = true
+	}
+	if resp.TLS != nil && p.tlsState == nil {
+		// 复用已有连接时没有握手过程，从响应中读取连接状态
+		p.tlsState = resp.TLS
+	}
+	return p
+}
+
+// reachability 经代理是否连接到目标
+func (p *proxyProbe) reachability(u *url.URL) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.connected {
+		if p.err != nil {
+			return "", fmt.Errorf("经代理 %s 无法连接 %s: %w", p.proxy.Host, hostPort(u), p.err)
+		}
+		return "", fmt.Errorf("经代理 %s 无法连接 %s (%d)", p.proxy.Host, hostPort(u), p.status)
+	}
+	return fmt.Sprintf("已经代理 %s 连接 %s", p.proxy.Host, hostPort(u)), nil
+}
+
+// handshake 经代理的 TLS 握手结果
+func (p *proxyProbe) handshake() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.tlsErr != nil:
+		return "", fmt.Errorf("TLS 握手失败: %w", p.tlsErr)
+	case p.tlsState != nil && len(p.tlsState.PeerCertificates) > 0:
+		return certSummary(p.tlsState.PeerCertificates[0]), nil
+	case p.err != nil:
+		return "", fmt.Errorf("TLS 握手失败: %w", p.err)
+	}
+	return "", fmt.Errorf("TLS 握手失败: 未获取到服务端证书")
+}
+
+// certSummary 返回证书名称和有效期
+func certSummary(cert *x509.Certificate) string {
+	return fmt.Sprintf("证书 %s，有效期至 %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+}
+
+// ProbeCapabilities 探测服务端支持的 API
+// 按当前 API 版本的接口路径，使用不会产生副作用的请求，根据是否返回 404 判断路由是否存在
+func (c *ZimaOSClient) ProbeCapabilities(baseURL, token string) map[string]bool {
+	caps := map[string]bool{}
+	p, err := c.api(baseURL)
+	if err != nil {
+		return caps
+	}
+	query := "?path=" + url.QueryEscape(StorageRoot)
+	caps[CapabilityMkdir] = c.routeExists("GET", baseURL, p.Mkdir, query, token)
+	caps[CapabilityUploadV2] = c.routeExists("GET", baseURL, p.Upload, "", token)
+	caps[CapabilityChunkUpload] = c.routeExists("GET", baseURL, p.ChunkUpload, "", token)
+	caps[CapabilityListFiles] = c.routeExists("GET", baseURL, p.List, query, token)
+	caps[CapabilityTasks] = c.routeExists("GET", baseURL, p.Tasks, "", token)
+	return caps
+}
+
+// routeExists 请求接口并判断路由是否存在 (405 等非 404 状态视为存在)，当前版本没有该接口时返回 false
+func (c *ZimaOSClient) routeExists(method, baseURL, apiPath, query, token string) bool {
+	if apiPath == "" {
+		return false
+	}
+	req, err := http.NewRequest(method, baseURL+apiPath+query, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Authorization", token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode != http.StatusNotFound
+}
+
+// hostPort 返回带默认端口的 host:port
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
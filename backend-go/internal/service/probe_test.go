@@ -0,0 +1,141 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"ftoz/internal/model"
+)
+
+// newTestProxy 启动支持 CONNECT 的 HTTP 代理，目标 zima.invalid:443 转发到 target，其他目标返回 502
+func newTestProxy(t *testing.T, target string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var connects atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT", http.StatusMethodNotAllowed)
+			return
+		}
+		connects.Add(1)
+		if r.Host != "zima.invalid:443" {
+			http.Error(w, "unknown host", http.StatusBadGateway)
+			return
+		}
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy, &connects
+}
+
+// newTLSTarget 启动使用自签名证书的 HTTPS 服务器，握手失败不输出日志
+func newTLSTarget(t *testing.T) *httptest.Server {
+	t.Helper()
+	target := httptest.NewUnstartedServer(http.NotFoundHandler())
+	target.Config.ErrorLog = log.New(io.Discard, "", 0)
+	target.StartTLS()
+	t.Cleanup(target.Close)
+	return target
+}
+
+func findCheck(report *model.ConnectionReport, name string) model.ConnectionCheck {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return model.ConnectionCheck{Name: name}
+}
+
+func TestConnectionThroughProxy(t *testing.T) {
+	target := newTLSTarget(t)
+	proxy, connects := newTestProxy(t, target.Listener.Addr().String())
+
+	// 目标主机名只能经代理解析；自签名证书以指纹信任
+	sum := sha256.Sum256(target.Certificate().RawSubjectPublicKeyInfo)
+	transport, err := NewHTTPTransport(model.TransportOptions{
+		Proxy: proxy.URL,
+		Pins:  []string{"sha256/" + base64.StdEncoding.EncodeToString(sum[:])},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewZimaOSClient()
+	client.SetTransport(transport)
+
+	report := client.TestConnection("https://zima.invalid", "u", "p")
+	for _, name := range []string{CheckURL, CheckReachability, CheckTLS} {
+		if check := findCheck(report, name); !check.OK {
+			t.Errorf("%s: %+v", name, check)
+		}
+	}
+	if connects.Load() == 0 {
+		t.Error("连接测试未经过代理")
+	}
+}
+
+func TestConnectionThroughProxyUnreachable(t *testing.T) {
+	proxy, _ := newTestProxy(t, "")
+	transport, err := NewHTTPTransport(model.TransportOptions{Proxy: proxy.URL}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewZimaOSClient()
+	client.SetTransport(transport)
+
+	report := client.TestConnection("https://other.invalid", "u", "p")
+	if check := findCheck(report, CheckReachability); check.OK {
+		t.Fatalf("代理无法连接目标时连通性检查应失败: %+v", check)
+	}
+	if check := findCheck(report, CheckTLS); !check.Skipped {
+		t.Errorf("连通性检查失败后 TLS 检查应跳过: %+v", check)
+	}
+	if report.OK {
+		t.Error("report.OK 应为 false")
+	}
+}
+
+func TestConnectionTLSPinMismatchThroughProxy(t *testing.T) {
+	target := newTLSTarget(t)
+	proxy, _ := newTestProxy(t, target.Listener.Addr().String())
+
+	transport, err := NewHTTPTransport(model.TransportOptions{
+		Proxy: proxy.URL,
+		Pins:  []string{"sha256/" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewZimaOSClient()
+	client.SetTransport(transport)
+
+	report := client.TestConnection("https://zima.invalid", "u", "p")
+	if check := findCheck(report, CheckReachability); !check.OK {
+		t.Errorf("已经代理连接到目标: %+v", check)
+	}
+	if check := findCheck(report, CheckTLS); check.OK {
+		t.Errorf("证书与指纹不匹配时 TLS 检查应失败: %+v", check)
+	}
+}
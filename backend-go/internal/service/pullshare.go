@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// PullShare 拉取模式下供 ZimaOS 读取源目录的临时 WebDAV 服务
+// 只读，使用随机生成的账号密码进行 Basic 认证；符号链接按 links 策略处理，特殊文件总是隐藏。
+// 共享使用未加密的 HTTP，账号密码和文件内容以明文传输，只应在可信的局域网中使用
+type PullShare struct {
+	Username string
+	Password string
+	Port     int
+
+	server   *http.Server
+	listener net.Listener
+}
+
+// StartPullShare 在本机地址 host 的 port 端口 (0 表示随机端口) 上启动只读 WebDAV 服务
+// 只监听 ZimaOS 访问本机使用的地址，不在其他网卡上暴露源目录
+func StartPullShare(dir, host string, port int, links string) (*PullShare, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("在 %s 上启动共享服务失败: %w", host, err)
+	}
+
+	share := &PullShare{
+		Username: "ftoz-" + randomToken(4),
+		Password: randomToken(16),
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		listener: listener,
+	}
+
+	dav := &webdav.Handler{
+		FileSystem: &shareFS{root: filepath.Clean(dir), follow: links == LinkPolicyFollow},
+		LockSystem: webdav.NewMemLS(),
+	}
+	share.server = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != share.Username || pass != share.Password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="ftoz"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			switch r.Method {
+			case "GET", "HEAD", "OPTIONS", "PROPFIND":
+				dav.ServeHTTP(w, r)
+			default:
+				http.Error(w, "Read-only share", http.StatusMethodNotAllowed)
+			}
+		}),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go share.server.Serve(listener)
+	return share, nil
+}
+
+// URL 返回以 host 访问共享服务的地址
+func (s *PullShare) URL(host string) string {
+	u := url.URL{Scheme: "http", Host: net.JoinHostPort(host, strconv.Itoa(s.Port))}
+	return u.String()
+}
+
+// Origin 返回不含端口的 http://host 地址，用于创建远程连接；IPv6 地址加方括号
+func (s *PullShare) Origin(host string) string {
+	u := url.URL{Scheme: "http", Host: host}
+	if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	}
+	return u.String()
+}
+
+// Close 停止共享服务
+func (s *PullShare) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// AdvertiseHost 返回本机访问 baseURL 所使用的 IP 地址，作为 ZimaOS 连接共享服务的地址
+func AdvertiseHost(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("无效的地址: %s", baseURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	// UDP 连接不发送数据，仅用于确定路由使用的本地地址
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return "", fmt.Errorf("无法确定本机地址: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// shareFS 只读文件系统，不允许访问源目录之外的路径
+type shareFS struct {
+	root   string
+	follow bool
+}
+
+func (f *shareFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (f *shareFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (f *shareFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (f *shareFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+	full, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(full)
+	if err != nil {
+		return nil, err
+	}
+	return &shareFile{File: file, fs: f, dir: full}, nil
+}
+
+func (f *shareFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	full, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(full)
+}
+
+// resolve 将请求路径转换为本地路径，逐级检查符号链接和特殊文件
+func (f *shareFS) resolve(name string) (string, error) {
+	rel := strings.TrimPrefix(path.Clean("/"+name), "/")
+	full := f.root
+	if rel == "" {
+		return full, nil
+	}
+
+	for _, part := range strings.Split(rel, "/") {
+		full = filepath.Join(full, part)
+		info, err := os.Lstat(full)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !f.follow {
+				return "", os.ErrNotExist
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return "", os.ErrNotExist
+		}
+	}
+
+	if f.follow {
+		// 链接目标须位于源目录内
+		real, err := filepath.EvalSymlinks(full)
+		if err != nil {
+			return "", os.ErrNotExist
+		}
+		root, err := filepath.EvalSymlinks(f.root)
+		if err != nil {
+			return "", err
+		}
+		if real != root && !strings.HasPrefix(real, root+string(filepath.Separator)) {
+			return "", os.ErrNotExist
+		}
+	}
+	return full, nil
+}
+
+// shareFile 列目录时按策略过滤符号链接和特殊文件
+type shareFile struct {
+	*os.File
+	fs  *shareFS
+	dir string
+}
+
+func (f *shareFile) Readdir(count int) ([]fs.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	kept := infos[:0]
+	for _, info := range infos {
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !f.fs.follow {
+				continue
+			}
+			rel, relErr := filepath.Rel(f.fs.root, filepath.Join(f.dir, info.Name()))
+			if relErr != nil {
+				continue
+			}
+			full, resolveErr := f.fs.resolve(filepath.ToSlash(rel))
+			if resolveErr != nil {
+				continue
+			}
+			target, statErr := os.Stat(full)
+			if statErr != nil {
+				continue
+			}
+			info = renamedInfo{FileInfo: target, name: info.Name()}
+		}
+		if info.Mode().IsRegular() || info.IsDir() {
+			kept = append(kept, info)
+		}
+	}
+	return kept, err
+}
+
+// renamedInfo 以链接名称展示链接目标的文件信息
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string { return i.name }
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"ftoz/internal/model"
+)
+
+const (
+	// QueueState 队列中任务的状态
+	QueueStateQueued  = "queued"
+	QueueStateRunning = "running"
+
+	// BusyPolicy 目标目录已被占用时的处理策略
+	BusyPolicyQueue  = "queue"
+	BusyPolicyReject = "reject"
+)
+
+// ErrDestinationBusy 目标目录已有迁移任务
+var ErrDestinationBusy = errors.New("目标目录已有迁移任务在执行或排队")
+
+// QueueEntry 队列中的任务
+type QueueEntry struct {
+	TaskID      string `json:"taskId"`
+	DestKey     string `json:"destKey"`
+	Params      string `json:"params"` // 传给 worker 的参数 JSON
+	State       string `json:"state"`  // queued/running
+	PID         int    `json:"pid,omitempty"`
+	StartTime   uint64 `json:"startTime,omitempty"` // worker 进程的启动时间 (/proc/<pid>/stat)，用于识别 PID 被复用
+	EnqueueTime int64  `json:"enqueueTime"`
+}
+
+// TaskQueue 基于文件的持久化任务队列
+// CGI 模式下每个请求都是独立进程，因此队列状态保存在文件中并通过文件锁互斥
+type TaskQueue struct {
+	dir        string
+	workerPath string
+	maxRunning int
+}
+
+// NewTaskQueue 创建任务队列
+func NewTaskQueue(workerPath string, maxRunning int) *TaskQueue {
+	if maxRunning <= 0 {
+		maxRunning = 1
+	}
+	return &TaskQueue{
+		dir:        StatusDir,
+		workerPath: workerPath,
+		maxRunning: maxRunning,
+	}
+}
+
+// Enqueue 将任务加入队列并尝试调度
+// 返回任务在队列中的位置 (0 表示已开始执行)
+func (q *TaskQueue) Enqueue(entry QueueEntry, busyPolicy string) (int, error) {
+	var position int
+	err := q.withLock(func(entries []QueueEntry) ([]QueueEntry, error) {
+		if busyPolicy == BusyPolicyReject {
+			for _, e := range entries {
+				if destinationsOverlap(e.DestKey, entry.DestKey) {
+					return nil, ErrDestinationBusy
+				}
+			}
+		}
+
+		entry.State = QueueStateQueued
+		entry.EnqueueTime = time.Now().Unix()
+		entries = q.dispatch(append(entries, entry))
+		position = queuePosition(entries, entry.TaskID)
+		return entries, nil
+	})
+	return position, err
+}
+
+// Finish 将任务移出队列并调度后续任务
+func (q *TaskQueue) Finish(taskId string) error {
+	return q.withLock(func(entries []QueueEntry) ([]QueueEntry, error) {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.TaskID != taskId {
+				kept = append(kept, e)
+			}
+		}
+		return q.dispatch(kept), nil
+	})
+}
+
+// Reap 清理已退出的 worker 并调度排队任务
+// 查询状态时调用，worker 异常退出 (未调用 Finish) 时不必等到下一次提交任务才继续调度
+func (q *TaskQueue) Reap() error {
+	return q.withLock(func(entries []QueueEntry) ([]QueueEntry, error) {
+		return q.dispatch(entries), nil
+	})
+}
+
+// Position 返回任务的排队位置 (从 1 开始)，不在排队中时返回 0
+func (q *TaskQueue) Position(taskId string) int {
+	entries, err := q.load()
+	if err != nil {
+		return 0
+	}
+	return queuePosition(entries, taskId)
+}
+
+// dispatch 在并发上限内启动排队任务，同一目标目录同时只运行一个任务
+func (q *TaskQueue) dispatch(entries []QueueEntry) []QueueEntry {
+	// 清理已退出的 worker
+	result := make([]QueueEntry, 0, len(entries))
+	running := 0
+	for _, e := range entries {
+		if e.State == QueueStateRunning {
+			if !processAlive(e.PID, e.StartTime) {
+				markWorkerExited(e.TaskID)
+				continue
+			}
+			running++
+		}
+		result = append(result, e)
+	}
+
+	started := make([]QueueEntry, 0, len(result))
+	for _, e := range result {
+		busy := destinationRunning(result, e.DestKey) || destinationRunning(started, e.DestKey)
+		if e.State == QueueStateQueued && running < q.maxRunning && !busy {
+			pid, err := q.startWorker(e)
+			if err != nil {
+				WriteTaskStatus(&model.TaskStatus{
+					TaskID:     e.TaskID,
+					Status:     "error",
+					Error:      "启动后台进程失败: " + err.Error(),
+					UpdateTime: time.Now().Unix(),
+				})
+				continue
+			}
+			e.State = QueueStateRunning
+			e.PID = pid
+			e.StartTime = processStartTime(pid)
+			running++
+		}
+		started = append(started, e)
+	}
+	return started
+}
+
+// startWorker 启动后台 worker 进程
+func (q *TaskQueue) startWorker(e QueueEntry) (int, error) {
+	cmd := exec.Command(q.workerPath, e.TaskID, e.Params)
+
+	// 设置进程独立运行，不受父进程影响
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	// 回收子进程，避免常驻服务下产生僵尸进程
+	go cmd.Wait()
+
+	return cmd.Process.Pid, nil
+}
+
+// withLock 在文件锁保护下读取、修改并保存队列
+func (q *TaskQueue) withLock(fn func(entries []QueueEntry) ([]QueueEntry, error)) error {
+	lock, err := os.OpenFile(filepath.Join(q.dir, "ftoz-queue.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	entries, err := q.load()
+	if err != nil {
+		return err
+	}
+
+	entries, err = fn(entries)
+	if err != nil {
+		return err
+	}
+
+	return q.save(entries)
+}
+
+func (q *TaskQueue) load() ([]QueueEntry, error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, "ftoz-queue.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []QueueEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (q *TaskQueue) save(entries []QueueEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	// 队列中包含登录密码，仅允许所有者读写
+	queueFile := filepath.Join(q.dir, "ftoz-queue.json")
+	tmpFile := queueFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, queueFile)
+}
+
+// DestinationKey 根据迁移请求生成目标目录标识
+func DestinationKey(req *model.MigrateRequest) string {
+	switch req.DestType {
+	case DestTypeLocal:
+		return LocalDestinationKey(req.DestPath)
+	case DestTypeWebDAV:
+		return strings.ToLower(req.BaseURL) + "|/" + strings.Trim(req.DestPath, "/")
+	case DestTypeSFTP:
+		return "sftp://" + strings.ToLower(strings.TrimPrefix(req.BaseURL, "sftp://")) + "|/" + strings.Trim(req.DestPath, "/")
+	case DestTypeS3:
+		return strings.ToLower(req.BaseURL) + "|/" + strings.Trim(req.Bucket+"/"+strings.Trim(req.DestPath, "/"), "/")
+	}
+	storagePath := "/media"
+	if req.Storage != "" {
+		storagePath = "/media/" + strings.Trim(req.Storage, "/")
+	}
+	return strings.ToLower(req.BaseURL) + "|" + storagePath
+}
+
+// LocalDestinationKey 返回下载到本地目录的任务的目标标识
+func LocalDestinationKey(dir string) string {
+	return "local|" + filepath.Clean(dir)
+}
+
+// destinationsOverlap 判断两个目标目录是否相同或互为父子目录
+func destinationsOverlap(a, b string) bool {
+	hostA, pathA, _ := strings.Cut(a, "|")
+	hostB, pathB, _ := strings.Cut(b, "|")
+	if hostA != hostB {
+		return false
+	}
+	return pathA == pathB ||
+		strings.HasPrefix(pathA, pathB+"/") ||
+		strings.HasPrefix(pathB, pathA+"/")
+}
+
+// destinationRunning 判断目标目录是否已有运行中的任务
+func destinationRunning(entries []QueueEntry, destKey string) bool {
+	for _, e := range entries {
+		if e.State == QueueStateRunning && destinationsOverlap(e.DestKey, destKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// queuePosition 返回任务在排队任务中的位置 (从 1 开始)
+func queuePosition(entries []QueueEntry, taskId string) int {
+	position := 0
+	for _, e := range entries {
+		if e.State != QueueStateQueued {
+			continue
+		}
+		position++
+		if e.TaskID == taskId {
+			return position
+		}
+	}
+	return 0
+}
+
+// processAlive 检查进程是否仍在运行
+// startTime 不为 0 时同时比较进程启动时间，PID 已被其他进程复用时视为已退出
+func processAlive(pid int, startTime uint64) bool {
+	if pid <= 0 || syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	if startTime == 0 {
+		return true
+	}
+	return processStartTime(pid) == startTime
+}
+
+// processStartTime 读取进程启动时间 (系统启动后的时钟周期数)，读取失败或进程已成为僵尸进程时返回 0
+func processStartTime(pid int) uint64 {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0
+	}
+	// 进程名可能包含空格和括号，从最后一个右括号之后开始解析: state(3) ... starttime(22)
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 || fields[0] == "Z" {
+		return 0
+	}
+	start, _ := strconv.ParseUint(fields[19], 10, 64)
+	return start
+}
+
+// markWorkerExited worker 未写入最终状态就退出时 (崩溃或被杀死) 将任务标记为失败
+func markWorkerExited(taskId string) {
+	status, err := ReadTaskStatus(taskId)
+	if err != nil || status.Status == "success" || status.Status == "error" {
+		return
+	}
+	status.Status = "error"
+	status.Error = "后台进程意外退出"
+	status.UpdateTime = time.Now().Unix()
+	WriteTaskStatus(status)
+}
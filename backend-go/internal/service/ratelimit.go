@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimitChunk 限速读取时单次读取的上限，避免一次取走过多令牌
+const rateLimitChunk = 32 << 10
+
+// ErrOutsideWindow 传输时段结束，正在进行的传输被中止
+var ErrOutsideWindow = errors.New("不在允许的传输时段，传输已中止")
+
+// RateLimiter 令牌桶限速器，所有并发传输共用同一速率
+// 令牌不足时先扣除再等待补足，因此单次读取大于桶容量时也能正常工作
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   int64 // 字节/秒，0 表示不限速
+	tokens float64
+	last   time.Time
+	gate   func() bool // 是否允许传输，nil 表示总是允许
+}
+
+// NewRateLimiter 创建限速器，rate 为每秒字节数
+func NewRateLimiter(rate int64) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetRate(rate)
+	return l
+}
+
+// SetRate 修改速率，立即对所有传输生效
+func (l *RateLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rate < 0 {
+		rate = 0
+	}
+	l.rate = rate
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+// SetGate 设置传输开关，gate 返回 false 时限速读取立即返回 ErrOutsideWindow，用于在传输时段结束时中止传输
+func (l *RateLimiter) SetGate(gate func() bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gate = gate
+}
+
+// open 当前是否允许传输
+func (l *RateLimiter) open() bool {
+	l.mu.Lock()
+	gate := l.gate
+	l.mu.Unlock()
+	return gate == nil || gate()
+}
+
+// Rate 返回当前速率
+func (l *RateLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// wait 取走 n 个令牌，不足时等待
+func (l *RateLimiter) wait(n int) {
+	l.mu.Lock()
+	if l.rate <= 0 || n <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	rate := float64(l.rate)
+	l.tokens += now.Sub(l.last).Seconds() * rate
+	if l.tokens > rate {
+		// 桶容量为 1 秒的流量
+		l.tokens = rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Reader 返回按限速读取 r 的 Reader
+func (l *RateLimiter) Reader(r io.Reader) io.Reader {
+	return &rateLimitedReader{r: r, limiter: l}
+}
+
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateLimitChunk {
+		p = p[:rateLimitChunk]
+	}
+	if !r.limiter.open() {
+		return 0, ErrOutsideWindow
+	}
+	n, err := r.r.Read(p)
+	r.limiter.wait(n)
+	return n, err
+}
+
+// uploadLimiter 上传限速器，由 worker 进程设置，nil 表示不限速
+var uploadLimiter *RateLimiter
+
+// SetUploadLimiter 设置各目标端上传文件内容时共用的限速器
+func SetUploadLimiter(l *RateLimiter) {
+	uploadLimiter = l
+}
+
+// limitUpload 按上传限速读取 r
+func limitUpload(r io.Reader) io.Reader {
+	if uploadLimiter == nil {
+		return r
+	}
+	return uploadLimiter.Reader(r)
+}
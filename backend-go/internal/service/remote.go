@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"ftoz/internal/model"
+)
+
+const (
+	// 迁移方向
+	DirectionUpload   = "upload"   // FNOS → ZimaOS (默认)
+	DirectionDownload = "download" // ZimaOS → FNOS
+)
+
+// RemoteEntry 远程扫描到的文件或目录
+type RemoteEntry struct {
+	RemoteFile
+	RelPath string // 相对扫描根目录的本地路径
+}
+
+// RemoteScanResult 远程目录扫描结果
+type RemoteScanResult struct {
+	Dirs       []RemoteEntry // 父目录在前
+	Files      []RemoteEntry
+	TotalBytes int64
+	Skipped    []model.SkippedPath
+}
+
+// ScanRemote 通过 getFiles 递归列出远程目录
+// 每读取完一个目录回调一次 onProgress
+func (c *ZimaOSClient) ScanRemote(baseURL, token, root string, onProgress func(model.ScanProgress)) (*RemoteScanResult, error) {
+	result := &RemoteScanResult{}
+	progress := model.ScanProgress{}
+
+	queue := []RemoteEntry{{RemoteFile: RemoteFile{Path: root, IsDir: true}}}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		files, err := c.ListFiles(baseURL, token, dir.Path)
+		if err != nil {
+			return nil, fmt.Errorf("读取远程目录 %s 失败: %w", dir.Path, err)
+		}
+		progress.DirsVisited++
+
+		for _, f := range files {
+			// 文件名来自远程，拒绝会逃出目标目录的名称
+			if f.Name == "" || strings.ContainsAny(f.Name, "/\x00") || f.Name == "." || f.Name == ".." {
+				result.Skipped = append(result.Skipped, model.SkippedPath{
+					Path:   path.Join(filepath.ToSlash(dir.RelPath), f.Name),
+					Type:   "file",
+					Reason: "文件名无法在本地使用",
+				})
+				progress.Skipped++
+				continue
+			}
+
+			entry := RemoteEntry{RemoteFile: f, RelPath: filepath.Join(dir.RelPath, f.Name)}
+			if f.IsDir {
+				result.Dirs = append(result.Dirs, entry)
+				queue = append(queue, entry)
+				progress.DirsFound++
+			} else {
+				result.Files = append(result.Files, entry)
+				result.TotalBytes += f.Size
+				progress.FilesFound++
+				progress.BytesFound += f.Size
+			}
+		}
+
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,589 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DestTypeS3 S3 兼容对象存储目标端
+	DestTypeS3 = "s3"
+
+	// DefaultS3Region 未指定区域时使用的区域 (MinIO 默认)
+	DefaultS3Region = "us-east-1"
+
+	// 超过 s3PartSize 的文件使用分片上传
+	s3PartSize = 64 << 20
+	s3MaxParts = 10000
+
+	// s3MetaMtime 保存原修改时间 (Unix 秒) 的对象元数据
+	s3MetaMtime = "X-Amz-Meta-Mtime"
+
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Destination S3 兼容对象存储目标端 (路径风格访问，签名 V4)
+// 对象键为 prefix/相对路径；对象存储没有目录，空目录不会保留。
+// 大文件使用分片上传，上传 ID 记录在 /tmp 下的状态文件中，任务中断后重新提交可继续上传未完成的分片
+type S3Destination struct {
+	client    *http.Client // 查询、创建和完成分片上传等请求
+	transfer  *http.Client // 对象和分片上传
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string
+}
+
+// s3UploadState 分片上传状态
+type s3UploadState struct {
+	UploadID string `json:"uploadId"`
+	Key      string `json:"key"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"modTime"`
+	PartSize int64  `json:"partSize"`
+}
+
+// s3Part 已上传的分片
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int64  `xml:"Size"`
+}
+
+// s3Error S3 错误响应
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// NewS3Destination 创建 S3 目标端，prefix 为对象键前缀
+func NewS3Destination(endpoint, region, bucket, accessKey, secretKey, prefix string) (*S3Destination, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("S3 地址无效: %s", endpoint)
+	}
+	if region == "" {
+		region = DefaultS3Region
+	}
+	return &S3Destination{
+		client: &http.Client{Timeout: 30 * time.Second},
+		// 上传不设置整体超时，避免大文件上传失败
+		transfer:  &http.Client{},
+		endpoint:  u,
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		prefix:    strings.Trim(prefix, "/"),
+	}, nil
+}
+
+// Authenticate 通过 HEAD Bucket 校验账号和存储桶
+func (d *S3Destination) Authenticate() error {
+	resp, err := d.do("HEAD", "", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("存储桶 %s 不存在", d.bucket)
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return fmt.Errorf("S3 访问密钥错误或无权访问存储桶 %s", d.bucket)
+	default:
+		return fmt.Errorf("连接 S3 失败(%d)", resp.StatusCode)
+	}
+}
+
+// Mkdir 对象存储没有目录，无需创建
+func (d *S3Destination) Mkdir(relDir string) error {
+	return nil
+}
+
+// PutFile 上传文件，原修改时间保存在 x-amz-meta-mtime 中
+func (d *S3Destination) PutFile(relPath, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("获取文件信息失败: %w", err)
+	}
+
+	key := d.key(relPath)
+	if stat.Size() > s3PartSize {
+		return d.putMultipart(key, file, stat.Size(), stat.ModTime().Unix())
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/octet-stream",
+		s3MetaMtime:    strconv.FormatInt(stat.ModTime().Unix(), 10),
+	}
+	var body io.Reader = file
+	if stat.Size() == 0 {
+		body = nil
+	}
+	resp, err := d.doSized("PUT", key, nil, body, stat.Size(), headers)
+	if err != nil {
+		return fmt.Errorf("上传请求失败: %w", err)
+	}
+	return d.check(resp, "上传")
+}
+
+// Stat 通过 HEAD Object 查询文件，修改时间优先取 x-amz-meta-mtime
+func (d *S3Destination) Stat(relPath string) (*DestEntry, error) {
+	if strings.Trim(relPath, "/") == "" {
+		return &DestEntry{IsDir: true}, nil
+	}
+	resp, err := d.do("HEAD", d.key(relPath), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询对象失败(%d)", resp.StatusCode)
+	}
+
+	entry := &DestEntry{Name: path.Base(relPath), Size: resp.ContentLength}
+	if sec, err := strconv.ParseInt(resp.Header.Get(s3MetaMtime), 10, 64); err == nil {
+		entry.ModTime = time.Unix(sec, 0)
+	} else if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		entry.ModTime = t
+		entry.Uploaded = true
+	}
+	return entry, nil
+}
+
+// List 通过 ListObjectsV2 列出目录下的对象和子目录 (公共前缀)
+// 列表结果不含对象元数据，ModTime 为上传时间
+func (d *S3Destination) List(relDir string) ([]DestEntry, error) {
+	prefix := d.key(relDir)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var entries []DestEntry
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		query.Set("delimiter", "/")
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := d.do("GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = d.decode(resp, "列出对象", &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			if name == "" {
+				continue
+			}
+			entries = append(entries, DestEntry{Name: name, Size: obj.Size, ModTime: obj.LastModified, Uploaded: true})
+		}
+		for _, p := range result.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/")
+			if name != "" {
+				entries = append(entries, DestEntry{Name: name, IsDir: true})
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return entries, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// putMultipart 分片上传，已上传的分片通过 ListParts 确认后跳过
+func (d *S3Destination) putMultipart(key string, file *os.File, size, mtime int64) error {
+	partSize := int64(s3PartSize)
+	for (size+partSize-1)/partSize > s3MaxParts {
+		partSize *= 2
+	}
+
+	statePath := d.statePath(key)
+	state := d.loadState(statePath, key, size, mtime, partSize)
+
+	var done map[int]s3Part
+	if state != nil {
+		parts, err := d.listParts(key, state.UploadID)
+		if err != nil {
+			// 上传已失效，重新开始
+			state = nil
+		} else {
+			done = make(map[int]s3Part, len(parts))
+			for _, p := range parts {
+				done[p.PartNumber] = p
+			}
+		}
+	}
+	if state == nil {
+		uploadID, err := d.createMultipart(key, mtime)
+		if err != nil {
+			return err
+		}
+		state = &s3UploadState{UploadID: uploadID, Key: key, Size: size, ModTime: mtime, PartSize: partSize}
+		if data, err := json.Marshal(state); err == nil {
+			os.WriteFile(statePath, data, 0600)
+		}
+	}
+
+	var parts []s3Part
+	for n, offset := 1, int64(0); offset < size; n, offset = n+1, offset+partSize {
+		length := min(partSize, size-offset)
+		if p, ok := done[n]; ok && p.Size == length {
+			parts = append(parts, p)
+			continue
+		}
+
+		query := url.Values{}
+		query.Set("partNumber", strconv.Itoa(n))
+		query.Set("uploadId", state.UploadID)
+		resp, err := d.doSized("PUT", key, query, io.NewSectionReader(file, offset, length), length, nil)
+		if err != nil {
+			return fmt.Errorf("上传分片 %d 失败: %w", n, err)
+		}
+		if err := d.check(resp, fmt.Sprintf("上传分片 %d", n)); err != nil {
+			return err
+		}
+		parts = append(parts, s3Part{PartNumber: n, ETag: resp.Header.Get("ETag"), Size: length})
+	}
+
+	if err := d.completeMultipart(key, state.UploadID, parts); err != nil {
+		return err
+	}
+	os.Remove(statePath)
+	return nil
+}
+
+func (d *S3Destination) createMultipart(key string, mtime int64) (string, error) {
+	headers := map[string]string{
+		"Content-Type": "application/octet-stream",
+		s3MetaMtime:    strconv.FormatInt(mtime, 10),
+	}
+	resp, err := d.do("POST", key, url.Values{"uploads": {""}}, nil, headers)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := d.decode(resp, "创建分片上传", &result); err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("创建分片上传失败: 未返回 UploadId")
+	}
+	return result.UploadID, nil
+}
+
+// listParts 列出已上传的分片
+func (d *S3Destination) listParts(key, uploadID string) ([]s3Part, error) {
+	var parts []s3Part
+	marker := ""
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker != "" {
+			query.Set("part-number-marker", marker)
+		}
+		resp, err := d.do("GET", key, query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Parts                []s3Part `xml:"Part"`
+			IsTruncated          bool     `xml:"IsTruncated"`
+			NextPartNumberMarker string   `xml:"NextPartNumberMarker"`
+		}
+		if err := d.decode(resp, "列出分片", &result); err != nil {
+			return nil, err
+		}
+		parts = append(parts, result.Parts...)
+		if !result.IsTruncated || result.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+func (d *S3Destination) completeMultipart(key, uploadID string, parts []s3Part) error {
+	var buf bytes.Buffer
+	buf.WriteString("<CompleteMultipartUpload>")
+	for _, p := range parts {
+		fmt.Fprintf(&buf, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", p.PartNumber, xmlEscape(p.ETag))
+	}
+	buf.WriteString("</CompleteMultipartUpload>")
+
+	resp, err := d.do("POST", key, url.Values{"uploadId": {uploadID}}, buf.Bytes(), map[string]string{"Content-Type": "application/xml"})
+	if err != nil {
+		return err
+	}
+	// 完成请求出错时也可能返回 200，需检查响应内容
+	var result struct {
+		XMLName xml.Name
+		s3Error
+	}
+	if err := d.decode(resp, "完成分片上传", &result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("完成分片上传失败: %s %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// loadState 读取与当前文件匹配的分片上传状态
+func (d *S3Destination) loadState(statePath, key string, size, mtime, partSize int64) *s3UploadState {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil
+	}
+	var state s3UploadState
+	if json.Unmarshal(data, &state) != nil || state.UploadID == "" ||
+		state.Key != key || state.Size != size || state.ModTime != mtime || state.PartSize != partSize {
+		return nil
+	}
+	return &state
+}
+
+// statePath 返回分片上传状态文件路径
+func (d *S3Destination) statePath(key string) string {
+	sum := sha1.Sum([]byte(d.endpoint.String() + "|" + d.bucket + "|" + key))
+	return filepath.Join(os.TempDir(), "ftoz-s3-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// key 将目标根目录下的相对路径转换为对象键
+func (d *S3Destination) key(relPath string) string {
+	return strings.Trim(path.Join(d.prefix, relPath), "/")
+}
+
+// do 发送请求，body 为完整请求体 (计算哈希参与签名)
+func (d *S3Destination) do(method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := d.newRequest(method, key, query, headers)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	d.sign(req, hex.EncodeToString(sum[:]), time.Now())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s 请求失败: %w", method, err)
+	}
+	return resp, nil
+}
+
+// doSized 发送流式请求体，请求体不参与签名 (UNSIGNED-PAYLOAD)
+func (d *S3Destination) doSized(method, key string, query url.Values, body io.Reader, size int64, headers map[string]string) (*http.Response, error) {
+	req, err := d.newRequest(method, key, query, headers)
+	if err != nil {
+		return nil, err
+	}
+	watch := watchStall(context.Background())
+	req = req.WithContext(watch.context())
+	if body != nil {
+		req.Body = io.NopCloser(limitUpload(watch.reader(body)))
+	}
+	req.ContentLength = size
+	d.sign(req, unsignedPayload, time.Now())
+	resp, err := d.transfer.Do(req)
+	if err != nil {
+		watch.stop()
+		return nil, watch.err(err)
+	}
+	resp.Body = watch.body(resp.Body)
+	return resp, nil
+}
+
+func (d *S3Destination) newRequest(method, key string, query url.Values, headers map[string]string) (*http.Request, error) {
+	u := *d.endpoint
+	u.Path = strings.TrimRight(d.endpoint.Path, "/") + "/" + d.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建 S3 请求失败: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// check 检查响应状态，关闭响应体
+func (d *S3Destination) check(resp *http.Response, action string) error {
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	var e s3Error
+	xml.NewDecoder(resp.Body).Decode(&e)
+	if e.Code != "" {
+		return fmt.Errorf("%s失败(%d): %s %s", action, resp.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Errorf("%s失败(%d)", action, resp.StatusCode)
+}
+
+// decode 检查响应状态并解析 XML 响应体
+func (d *S3Destination) decode(resp *http.Response, action string, out interface{}) error {
+	if resp.StatusCode >= 300 {
+		return d.check(resp, action)
+	}
+	defer resp.Body.Close()
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析%s响应失败: %w", action, err)
+	}
+	return nil
+}
+
+// sign 按 AWS 签名 V4 为请求签名，签名包含 Host 及请求中已设置的全部请求头
+func (d *S3Destination) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	signed := map[string]string{"host": host}
+	for k, v := range req.Header {
+		signed[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(signed))
+	for k := range signed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + signed[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + d.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+d.secretKey), date)
+	key = hmacSHA256(key, d.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		d.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery 按签名 V4 规则编码查询参数 (按名称排序)
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape 按 RFC 3986 编码，仅保留非保留字符 (路径中同时保留 /)
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -1,44 +1,704 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ftoz/internal/model"
+	"ftoz/internal/util"
 )
 
+// maxScanErrors 扫描结果中保留的错误明细上限
+const maxScanErrors = 1000
+
 // Scanner 目录扫描器
-type Scanner struct{}
+// 使用多个协程并发读取目录，发现的条目通过通道流式输出
+type Scanner struct {
+	opts ScanOptions
+}
+
+// ScanOptions 扫描选项
+type ScanOptions struct {
+	Workers       int    // 并发读取目录的协程数，默认为 CPU 核数 (至少 4，I/O 密集)
+	Strict        bool   // 遇到无法读取的目录或文件时立即失败；默认记录错误并跳过
+	Links         string // 符号链接处理策略，默认 skip
+	OneFileSystem bool   // 不进入与根目录不在同一文件系统的目录
+	NameCharset   string // 非 UTF-8 文件名的编码，默认自动识别
+	Normalize     string // 文件名 Unicode 规范化，默认 NFC
+	OnCollision   string // 目标端重名处理策略，默认 rename
+}
+
+// ScanEntry 扫描到的文件或目录
+type ScanEntry struct {
+	Path    string    // 相对路径
+	DstPath string    // 目标端相对路径 (UTF-8)
+	IsDir   bool      // 是否为目录
+	Size    int64     // 文件大小 (字节)
+	ModTime time.Time // 修改时间
+
+	// HardLink 有多个硬链接的文件的设备号和 inode ("dev:ino")，其他条目为空
+	// 同一 inode 的各路径中输出哪一个取决于遍历顺序，重新扫描时可能不同
+	HardLink string
+}
+
+// DirStat 目录统计 (包含所有子目录)
+type DirStat struct {
+	Files int   `json:"files"`
+	Dirs  int   `json:"dirs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// ScanSummary 扫描汇总
+type ScanSummary struct {
+	TotalFiles  int
+	TotalDirs   int
+	TotalBytes  int64
+	DirStats    map[string]*DirStat // 以相对路径为键，根目录为 ""
+	Errors      []model.ScanError   // 跳过的路径及原因 (最多保留 maxScanErrors 条)
+	ErrorCount  int                 // 跳过的路径总数
+	SkippedDirs int                 // 无法读取而跳过的目录数
+
+	Skipped      []model.SkippedPath   // 按策略跳过的路径 (最多保留 maxScanErrors 条)
+	SkippedCount int                   // 按策略跳过的路径总数
+	Symlinks     []model.LinkRecord    // preserve 模式下记录的符号链接
+	HardLinks    []model.HardLinkGroup // 硬链接组，重复路径不输出
+	Renamed      []model.PathMapping   // 在目标端改名的路径
+	Collisions   []model.NameCollision // 目标端重名的文件
+}
+
+// ScanResult 扫描结果
+type ScanResult struct {
+	ScanSummary
+	Files []ScanEntry
+	Dirs  []ScanEntry // 父目录在前
+}
+
+// ScanStream 流式扫描
+type ScanStream struct {
+	Entries <-chan ScanEntry
+
+	progress struct {
+		dirsVisited atomic.Int64
+		dirsFound   atomic.Int64
+		filesFound  atomic.Int64
+		bytesFound  atomic.Int64
+		errors      atomic.Int64
+		skipped     atomic.Int64
+	}
+	cancel  context.CancelFunc
+	done    chan struct{}
+	summary *ScanSummary
+	err     error
+}
 
 // NewScanner 创建扫描器
-func NewScanner() *Scanner {
-	return &Scanner{}
+func NewScanner(opts ScanOptions) *Scanner {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+		if opts.Workers < 4 {
+			opts.Workers = 4
+		}
+	}
+	return &Scanner{opts: opts}
 }
 
-// Scan 递归扫描目录，返回文件列表和目录列表（相对路径）
-func (s *Scanner) Scan(rootDir string) (files []string, dirs []string, err error) {
-	stack := []string{""}
+// Scan 递归扫描目录，返回文件列表、目录列表（相对路径）及统计信息
+func (s *Scanner) Scan(rootDir string) (*ScanResult, error) {
+	return s.ScanWithProgress(rootDir, nil)
+}
 
-	for len(stack) > 0 {
-		// Pop
-		relDir := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
+// ScanWithProgress 与 Scan 相同，扫描过程中定期回调进度
+func (s *Scanner) ScanWithProgress(rootDir string, onProgress func(model.ScanProgress)) (*ScanResult, error) {
+	stream := s.Stream(context.Background(), rootDir)
+	result := &ScanResult{}
 
-		absDir := filepath.Join(rootDir, relDir)
-		entries, err := os.ReadDir(absDir)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-stream.Entries:
+			if !ok {
+				summary, err := stream.Wait()
+				if err != nil {
+					return nil, err
+				}
+				result.ScanSummary = *summary
+				return result, nil
+			}
+			if entry.IsDir {
+				result.Dirs = append(result.Dirs, entry)
+			} else {
+				result.Files = append(result.Files, entry)
+			}
+		case <-ticker.C:
+			if onProgress != nil {
+				onProgress(stream.Progress())
+			}
+		}
+	}
+}
+
+// Stream 开始并发扫描，条目在发现后立即写入 Entries 通道
+// 目录条目总是先于其子条目输出。调用方必须读完 Entries 或调用 Cancel
+func (s *Scanner) Stream(ctx context.Context, rootDir string) *ScanStream {
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan ScanEntry, 256)
+	stream := &ScanStream{
+		Entries: out,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	w := &walker{
+		opts:   s.opts,
+		root:   rootDir,
+		ctx:    ctx,
+		out:    out,
+		stream: stream,
+		stats:  map[string]*DirStat{},
+		inodes: map[fileID]string{},
+		links:  map[string][]string{},
+		names:  newNameMapper(s.opts),
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	root := dirTask{}
+	if info, err := os.Stat(rootDir); err == nil {
+		if id, _, ok := statID(info); ok {
+			w.rootDev = id.dev
+			w.hasRootDev = true
+			root.chain = []fileID{id}
+		}
+	}
+	w.push(root)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+		if w.err == nil && ctx.Err() != nil {
+			w.err = ctx.Err()
+		}
+		stream.err = w.err
+		if w.err == nil {
+			stream.summary = w.summary()
+		}
+		cancel()
+		close(stream.done)
+	}()
+
+	return stream
+}
+
+// Progress 返回当前扫描进度
+func (st *ScanStream) Progress() model.ScanProgress {
+	return model.ScanProgress{
+		DirsVisited: st.progress.dirsVisited.Load(),
+		DirsFound:   st.progress.dirsFound.Load(),
+		FilesFound:  st.progress.filesFound.Load(),
+		BytesFound:  st.progress.bytesFound.Load(),
+		Errors:      st.progress.errors.Load(),
+		Skipped:     st.progress.skipped.Load(),
+	}
+}
+
+// Cancel 停止扫描
+func (st *ScanStream) Cancel() {
+	st.cancel()
+}
+
+// Wait 等待扫描结束并返回汇总
+func (st *ScanStream) Wait() (*ScanSummary, error) {
+	<-st.done
+	return st.summary, st.err
+}
+
+// walker 并发遍历状态
+type walker struct {
+	opts   ScanOptions
+	root   string
+	names  *nameMapper
+	ctx    context.Context
+	out    chan<- ScanEntry
+	stream *ScanStream
+
+	rootDev    uint64
+	hasRootDev bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []dirTask
+	pending int // 已入队但尚未处理完成的目录数
+	err     error
+
+	statsMu     sync.Mutex
+	stats       map[string]*DirStat // 每个目录的直接子项统计
+	errors      []model.ScanError
+	errorCount  int
+	skippedDirs int
+
+	skipped      []model.SkippedPath
+	skippedCount int
+	symlinks     []model.LinkRecord
+	inodes       map[fileID]string   // 硬链接 inode -> 首个路径 (目标端)
+	links        map[string][]string // 首个路径 -> 重复路径 (目标端)
+	renamed      []model.PathMapping
+	collisions   []model.NameCollision
+}
+
+// dirTask 待扫描目录
+type dirTask struct {
+	rel   string   // 相对路径
+	dst   string   // 目标端相对路径
+	chain []fileID // follow 模式下从根目录到该目录的标识，用于检测循环链接
+}
+
+// node 目录中的一个条目
+type node struct {
+	rel    string // 相对路径
+	dst    string // 目标端相对路径
+	reason string // 目标端改名原因，未改名时为空
+}
+
+// contains 判断目录是否为当前目录本身或其上级目录
+func (t dirTask) contains(id fileID) bool {
+	for _, c := range t.chain {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *walker) push(task dirTask) {
+	w.mu.Lock()
+	w.queue = append(w.queue, task)
+	w.pending++
+	w.mu.Unlock()
+	w.cond.Signal()
+}
+
+// pop 取出一个待扫描目录，全部完成或出错时返回 false
+func (w *walker) pop() (dirTask, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && w.pending > 0 && w.err == nil && w.ctx.Err() == nil {
+		w.cond.Wait()
+	}
+	if len(w.queue) == 0 || w.err != nil || w.ctx.Err() != nil {
+		return dirTask{}, false
+	}
+	// 后进先出，优先深入子目录，减少队列长度
+	task := w.queue[len(w.queue)-1]
+	w.queue = w.queue[:len(w.queue)-1]
+	return task, true
+}
+
+func (w *walker) finish(err error) {
+	w.mu.Lock()
+	w.pending--
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+func (w *walker) run() {
+	// 取消时唤醒等待中的协程
+	stop := context.AfterFunc(w.ctx, w.cond.Broadcast)
+	defer stop()
+
+	for {
+		task, ok := w.pop()
+		if !ok {
+			return
+		}
+		w.finish(w.visit(task))
+	}
+}
+
+// visit 读取单个目录，输出其中的条目并将子目录加入队列
+// 非严格模式下，无法读取的目录或文件记录到错误列表后跳过
+func (w *walker) visit(task dirTask) error {
+	relDir := task.rel
+	entries, err := os.ReadDir(filepath.Join(w.root, relDir))
+	if err != nil {
+		// 根目录无法读取时总是失败
+		if w.opts.Strict || relDir == "" {
+			return err
+		}
+		w.skip(relDir, true, err)
+		// ReadDir 出错时仍可能返回部分条目，继续处理已读取的部分
+		if len(entries) == 0 {
+			return nil
+		}
+	}
+	w.stream.progress.dirsVisited.Add(1)
+
+	// 计算目标端名称，重名策略为 fail 时总是失败
+	planned, collisions, err := w.names.plan(task.dst, entries, w.uploadable)
+	if len(collisions) > 0 {
+		w.statsMu.Lock()
+		w.collisions = append(w.collisions, collisions...)
+		w.statsMu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	stat := &DirStat{}
+	for i, entry := range entries {
+		relPath := filepath.Join(relDir, entry.Name())
+		n := node{rel: relPath, dst: filepath.Join(task.dst, planned[i].dst), reason: planned[i].reason}
+		mode := entry.Type()
+
+		if planned[i].skip != "" {
+			w.skipPath(relPath, entryType(entry), planned[i].skip)
+			continue
+		}
+
+		if mode&fs.ModeSymlink != 0 {
+			ok, err := w.symlink(task, n, stat)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			continue
+		}
+		if !entry.IsDir() && !mode.IsRegular() {
+			w.skipPath(relPath, specialType(mode), "特殊文件，不支持迁移")
+			continue
+		}
+
+		info, err := entry.Info()
 		if err != nil {
-			return nil, nil, err
+			if w.opts.Strict {
+				return err
+			}
+			w.skip(relPath, entry.IsDir(), err)
+			continue
+		}
+
+		var ok bool
+		if entry.IsDir() {
+			ok = w.addDir(task, n, info, stat)
+		} else {
+			ok = w.addFile(n, info, stat)
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	w.statsMu.Lock()
+	w.stats[relDir] = stat
+	w.statsMu.Unlock()
+	return nil
+}
+
+// addDir 输出目录条目并加入扫描队列，取消时返回 false
+func (w *walker) addDir(parent dirTask, n node, info os.FileInfo, stat *DirStat) bool {
+	id, _, hasID := statID(info)
+	if hasID && w.otherDevice(id) {
+		w.skipPath(n.rel, "dir", "位于其他文件系统 (oneFileSystem)")
+		return true
+	}
+
+	if !w.emit(ScanEntry{Path: n.rel, DstPath: n.dst, IsDir: true, ModTime: info.ModTime()}) {
+		return false
+	}
+	w.rename(n)
+	stat.Dirs++
+	w.stream.progress.dirsFound.Add(1)
+
+	task := dirTask{rel: n.rel, dst: n.dst}
+	if w.opts.Links == LinkPolicyFollow && hasID {
+		task.chain = append(parent.chain[:len(parent.chain):len(parent.chain)], id)
+	}
+	// 先输出目录条目再入队，保证父目录先于子条目输出
+	w.push(task)
+	return true
+}
+
+// addFile 输出文件条目，硬链接的重复路径只记录不输出，取消时返回 false
+func (w *walker) addFile(n node, info os.FileInfo, stat *DirStat) bool {
+	entry := ScanEntry{Path: n.rel, DstPath: n.dst, Size: info.Size(), ModTime: info.ModTime()}
+	if id, nlink, ok := statID(info); ok && nlink > 1 {
+		if w.hardLink(id, n.dst) {
+			return true
 		}
+		entry.HardLink = fmt.Sprintf("%d:%d", id.dev, id.ino)
+	}
 
-		for _, entry := range entries {
-			relPath := filepath.Join(relDir, entry.Name())
+	if !w.emit(entry) {
+		return false
+	}
+	w.rename(n)
+	stat.Files++
+	stat.Bytes += info.Size()
+	w.stream.progress.filesFound.Add(1)
+	w.stream.progress.bytesFound.Add(info.Size())
+	return true
+}
+
+// symlink 按链接策略处理符号链接，取消时返回 false
+func (w *walker) symlink(parent dirTask, n node, stat *DirStat) (bool, error) {
+	fullPath := filepath.Join(w.root, n.rel)
 
-			if entry.IsDir() {
-				dirs = append(dirs, relPath)
-				stack = append(stack, relPath)
-			} else if entry.Type().IsRegular() {
-				files = append(files, relPath)
+	switch w.opts.Links {
+	case LinkPolicyFollow:
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			w.skipPath(n.rel, "symlink", "链接目标无法访问: "+err.Error())
+			return true, nil
+		}
+		if info.IsDir() {
+			if id, _, ok := statID(info); ok && parent.contains(id) {
+				w.skipPath(n.rel, "symlink", "链接指向上级目录，形成循环")
+				return true, nil
 			}
+			return w.addDir(parent, n, info, stat), nil
+		}
+		if !info.Mode().IsRegular() {
+			w.skipPath(n.rel, specialType(info.Mode()), "链接目标为特殊文件，不支持迁移")
+			return true, nil
+		}
+		if id, _, ok := statID(info); ok && w.otherDevice(id) {
+			w.skipPath(n.rel, "symlink", "链接目标位于其他文件系统 (oneFileSystem)")
+			return true, nil
+		}
+		return w.addFile(n, info, stat), nil
+
+	case LinkPolicyPreserve:
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			if w.opts.Strict {
+				return false, err
+			}
+			w.skip(n.rel, false, err)
+			return true, nil
+		}
+		w.statsMu.Lock()
+		// 链接目标与文件名使用相同的编码转换
+		target, _ = w.names.target(target)
+		w.symlinks = append(w.symlinks, model.LinkRecord{
+			Path:   filepath.ToSlash(n.dst),
+			Source: n.rel,
+			Target: target,
+		})
+		w.statsMu.Unlock()
+		w.rename(n)
+		return true, nil
+
+	default:
+		w.skipPath(n.rel, "symlink", "符号链接 (links=skip)")
+		return true, nil
+	}
+}
+
+// otherDevice 判断启用 OneFileSystem 时文件是否位于其他文件系统
+func (w *walker) otherDevice(id fileID) bool {
+	return w.opts.OneFileSystem && w.hasRootDev && id.dev != w.rootDev
+}
+
+// hardLink 记录硬链接，已见过该 inode 时返回 true
+func (w *walker) hardLink(id fileID, dstPath string) bool {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	first, ok := w.inodes[id]
+	if !ok {
+		w.inodes[id] = dstPath
+		return false
+	}
+	w.links[first] = append(w.links[first], dstPath)
+	return true
+}
+
+// rename 记录在目标端改名的路径
+func (w *walker) rename(n node) {
+	if n.reason == "" {
+		return
+	}
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	w.renamed = append(w.renamed, model.PathMapping{
+		Path:    util.EscapeInvalidUTF8(filepath.ToSlash(n.rel)),
+		DstPath: filepath.ToSlash(n.dst),
+		Reason:  n.reason,
+	})
+}
+
+// uploadable 判断条目是否会出现在目标端，用于重名检测
+func (w *walker) uploadable(entry fs.DirEntry) bool {
+	mode := entry.Type()
+	if mode&fs.ModeSymlink != 0 {
+		return w.opts.Links == LinkPolicyFollow || w.opts.Links == LinkPolicyPreserve
+	}
+	return entry.IsDir() || mode.IsRegular()
+}
+
+// entryType 返回条目类型名称
+func entryType(entry fs.DirEntry) string {
+	switch {
+	case entry.IsDir():
+		return "dir"
+	case entry.Type().IsRegular():
+		return "file"
+	default:
+		return specialType(entry.Type())
+	}
+}
+
+// skipPath 记录按策略跳过的路径
+func (w *walker) skipPath(relPath, fileType, reason string) {
+	w.stream.progress.skipped.Add(1)
+
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	w.skippedCount++
+	if len(w.skipped) < maxScanErrors {
+		w.skipped = append(w.skipped, model.SkippedPath{
+			Path:   util.EscapeInvalidUTF8(filepath.ToSlash(relPath)),
+			Type:   fileType,
+			Reason: reason,
+		})
+	}
+}
+
+// skip 记录被跳过的路径
+func (w *walker) skip(relPath string, isDir bool, err error) {
+	w.stream.progress.errors.Add(1)
+
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	w.errorCount++
+	if isDir {
+		w.skippedDirs++
+	}
+	if len(w.errors) < maxScanErrors {
+		w.errors = append(w.errors, model.ScanError{
+			Path:  util.EscapeInvalidUTF8(filepath.ToSlash(relPath)),
+			IsDir: isDir,
+			Error: err.Error(),
+		})
+	}
+}
+
+func (w *walker) emit(entry ScanEntry) bool {
+	select {
+	case w.out <- entry:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+// TopDirs 返回源目录下各一级子目录的统计 (包含所有子目录)，按大小降序，最多 limit 条
+func (s *ScanSummary) TopDirs(limit int) []model.DirStat {
+	var dirs []model.DirStat
+	for rel, stat := range s.DirStats {
+		if rel == "" || filepath.Dir(rel) != "." {
+			continue
 		}
+		dirs = append(dirs, model.DirStat{
+			Path:  util.EscapeInvalidUTF8(filepath.ToSlash(rel)),
+			Files: stat.Files,
+			Dirs:  stat.Dirs,
+			Bytes: stat.Bytes,
+		})
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Bytes != dirs[j].Bytes {
+			return dirs[i].Bytes > dirs[j].Bytes
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	if len(dirs) > limit {
+		dirs = dirs[:limit]
+	}
+	return dirs
+}
+
+// summary 汇总统计，将每个目录的直接子项统计累加到所有上级目录
+func (w *walker) summary() *ScanSummary {
+	summary := &ScanSummary{
+		DirStats:    make(map[string]*DirStat, len(w.stats)),
+		Errors:      w.errors,
+		ErrorCount:  w.errorCount,
+		SkippedDirs: w.skippedDirs,
+
+		Skipped:      w.skipped,
+		SkippedCount: w.skippedCount,
+		Symlinks:     w.symlinks,
+		Renamed:      w.renamed,
+		Collisions:   w.collisions,
 	}
 
-	return files, dirs, nil
+	for first, dups := range w.links {
+		group := model.HardLinkGroup{Path: filepath.ToSlash(first)}
+		for _, dup := range dups {
+			group.Links = append(group.Links, filepath.ToSlash(dup))
+		}
+		sort.Strings(group.Links)
+		summary.HardLinks = append(summary.HardLinks, group)
+	}
+	sort.Slice(summary.HardLinks, func(i, j int) bool {
+		return summary.HardLinks[i].Path < summary.HardLinks[j].Path
+	})
+	sort.Slice(summary.Renamed, func(i, j int) bool {
+		return summary.Renamed[i].DstPath < summary.Renamed[j].DstPath
+	})
+	sort.SliceStable(summary.Collisions, func(i, j int) bool {
+		return summary.Collisions[i].Dir < summary.Collisions[j].Dir
+	})
+	sort.Slice(summary.Symlinks, func(i, j int) bool {
+		return summary.Symlinks[i].Path < summary.Symlinks[j].Path
+	})
+
+	for relDir, stat := range w.stats {
+		summary.TotalFiles += stat.Files
+		summary.TotalDirs += stat.Dirs
+		summary.TotalBytes += stat.Bytes
+
+		dir := relDir
+		for {
+			total, ok := summary.DirStats[dir]
+			if !ok {
+				total = &DirStat{}
+				summary.DirStats[dir] = total
+			}
+			total.Files += stat.Files
+			total.Dirs += stat.Dirs
+			total.Bytes += stat.Bytes
+
+			if dir == "" {
+				break
+			}
+			dir = filepath.Dir(dir)
+			if dir == "." {
+				dir = ""
+			}
+		}
+	}
+	return summary
 }
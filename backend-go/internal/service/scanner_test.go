@@ -0,0 +1,59 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"ftoz/internal/model"
+)
+
+func TestScanDirStats(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]int{
+		"top.txt":           1,
+		"photos/a.jpg":      100,
+		"photos/2024/b.jpg": 200,
+		"photos/2024/c.jpg": 300,
+		"docs/readme.md":    10,
+		"docs/old/x/y.txt":  5,
+	}
+	for rel, size := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewScanner(ScanOptions{}).Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 各目录统计包含所有子目录
+	if got := *result.DirStats[""]; got != (DirStat{Files: 6, Dirs: 6, Bytes: 616}) {
+		t.Errorf("根目录统计 = %+v", got)
+	}
+	if got := *result.DirStats[filepath.Join("docs", "old")]; got != (DirStat{Files: 1, Dirs: 1, Bytes: 5}) {
+		t.Errorf("docs/old 统计 = %+v", got)
+	}
+
+	want := []model.DirStat{
+		{Path: "photos", Files: 3, Dirs: 1, Bytes: 600},
+		{Path: "docs", Files: 2, Dirs: 2, Bytes: 15},
+		{Path: "empty"},
+	}
+	if got := result.TopDirs(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("TopDirs = %+v, want %+v", got, want)
+	}
+	if got := result.TopDirs(1); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("TopDirs(1) = %+v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransferWindow 允许传输的时段，以当天零点起的分钟数表示，End 小于 Start 时跨越午夜
+type TransferWindow struct {
+	Start int
+	End   int
+}
+
+// TransferSchedule 允许传输的时段列表，为空时不限制
+type TransferSchedule []TransferWindow
+
+// ParseSchedule 解析形如 "22:00-07:00" 的时段列表，时间为本机时区
+func ParseSchedule(specs []string) (TransferSchedule, error) {
+	var schedule TransferSchedule
+	for _, spec := range specs {
+		spec = strings.ReplaceAll(strings.TrimSpace(spec), "–", "-")
+		if spec == "" {
+			continue
+		}
+		start, end, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("无效的传输时段: %s", spec)
+		}
+		s, err := parseClock(start)
+		if err != nil {
+			return nil, fmt.Errorf("无效的传输时段: %s", spec)
+		}
+		e, err := parseClock(end)
+		if err != nil || s == e {
+			return nil, fmt.Errorf("无效的传输时段: %s", spec)
+		}
+		schedule = append(schedule, TransferWindow{Start: s, End: e})
+	}
+	return schedule, nil
+}
+
+// parseClock 解析 HH:MM，24:00 表示午夜
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil {
+		return 0, err
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("无效的时间: %s", s)
+	}
+	return (h*60 + m) % (24 * 60), nil
+}
+
+// Allowed 时间 t 是否在允许的时段内
+func (s TransferSchedule) Allowed(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range s {
+		if w.Start < w.End {
+			if minute >= w.Start && minute < w.End {
+				return true
+			}
+		} else if minute >= w.Start || minute < w.End {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStart 返回 t 之后最近的时段开始时间
+func (s TransferSchedule) NextStart(t time.Time) time.Time {
+	var next time.Time
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for _, w := range s {
+		start := midnight.Add(time.Duration(w.Start) * time.Minute)
+		if !start.After(t) {
+			start = start.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return next
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// DestTypeSFTP SFTP 目标端
+	DestTypeSFTP = "sftp"
+
+	// sftpPartSuffix 上传中的临时文件后缀
+	sftpPartSuffix = ".ftoz-part"
+	// sftpMaxNameBytes 临时文件名的最大字节数 (常见文件系统的 NAME_MAX)
+	sftpMaxNameBytes = 255
+)
+
+// SFTPDestination SFTP 目标端
+// 主机密钥须与 known_hosts 中的记录一致。文件先写入同目录下以源文件大小和修改时间命名的临时文件，
+// 完成后通过 SETSTAT 设置修改时间再改名；任务中断后重新提交时从临时文件的大小处继续追加
+type SFTPDestination struct {
+	addr       string
+	username   string
+	password   string
+	keyFile    string
+	passphrase string
+	knownHosts string
+	root       string
+
+	client *sftp.Client
+}
+
+// SFTPConfig SFTP 连接参数
+type SFTPConfig struct {
+	Addr       string // host 或 host:port，可带 sftp:// 前缀
+	Username   string
+	Password   string
+	KeyFile    string // 私钥文件路径
+	Passphrase string // 私钥密码
+	KnownHosts string // known_hosts 文件路径，默认 ~/.ssh/known_hosts
+}
+
+// NewSFTPDestination 创建 SFTP 目标端，root 为服务器上的目标目录 (相对路径基于登录用户的主目录)
+func NewSFTPDestination(cfg SFTPConfig, root string) (*SFTPDestination, error) {
+	addr := strings.TrimPrefix(strings.TrimRight(cfg.Addr, "/"), "sftp://")
+	if addr == "" {
+		return nil, fmt.Errorf("SFTP 地址无效: %s", cfg.Addr)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "22")
+	}
+
+	knownHosts := cfg.KnownHosts
+	if knownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("未指定 known_hosts 文件")
+		}
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	return &SFTPDestination{
+		addr:       addr,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		keyFile:    cfg.KeyFile,
+		passphrase: cfg.Passphrase,
+		knownHosts: knownHosts,
+		root:       path.Clean(root),
+	}, nil
+}
+
+// Authenticate 连接服务器，校验主机密钥并登录，然后创建目标根目录
+func (d *SFTPDestination) Authenticate() error {
+	var auth []ssh.AuthMethod
+	if d.keyFile != "" {
+		data, err := os.ReadFile(d.keyFile)
+		if err != nil {
+			return fmt.Errorf("读取私钥失败: %w", err)
+		}
+		var signer ssh.Signer
+		if d.passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(d.passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(data)
+		}
+		if err != nil {
+			return fmt.Errorf("解析私钥失败: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if d.password != "" {
+		auth = append(auth, ssh.Password(d.password))
+	}
+	if len(auth) == 0 {
+		return fmt.Errorf("缺少 SFTP 密码或私钥")
+	}
+
+	hostKeys, err := knownhosts.New(d.knownHosts)
+	if err != nil {
+		return fmt.Errorf("读取 known_hosts 失败: %w", err)
+	}
+
+	conn, err := ssh.Dial("tcp", d.addr, &ssh.ClientConfig{
+		User:            d.username,
+		Auth:            auth,
+		HostKeyCallback: verifyHostKey(hostKeys),
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("SFTP 连接失败: %w", err)
+	}
+	client, err := sftp.NewClient(conn, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("启动 SFTP 会话失败: %w", err)
+	}
+	d.client = client
+
+	if err := client.MkdirAll(d.root); err != nil {
+		return fmt.Errorf("创建目标目录失败: %w", err)
+	}
+	return nil
+}
+
+// verifyHostKey 按 known_hosts 校验主机密钥，未知主机时在错误中给出指纹
+func verifyHostKey(hostKeys ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := hostKeys(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("主机 %s 不在 known_hosts 中 (%s %s)，请确认后添加", hostname, key.Type(), ssh.FingerprintSHA256(key))
+			}
+			return fmt.Errorf("主机 %s 的密钥与 known_hosts 不符 (%s %s)，可能存在中间人攻击", hostname, key.Type(), ssh.FingerprintSHA256(key))
+		}
+		return err
+	}
+}
+
+// Mkdir 递归创建目录
+func (d *SFTPDestination) Mkdir(relDir string) error {
+	if err := d.client.MkdirAll(d.remotePath(relDir)); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	return nil
+}
+
+// SetDirTime 通过 SETSTAT 设置目录修改时间
+func (d *SFTPDestination) SetDirTime(relDir string, mtime time.Time) error {
+	return d.client.Chtimes(d.remotePath(relDir), mtime, mtime)
+}
+
+// PutFile 流式上传文件，存在同一源文件的临时文件时从其大小处继续
+func (d *SFTPDestination) PutFile(relPath, localPath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("获取文件信息失败: %w", err)
+	}
+
+	remote := d.remotePath(relPath)
+	part := sftpPartPath(remote, stat.Size(), stat.ModTime())
+
+	var offset int64
+	if info, err := d.client.Stat(part); err == nil && info.Mode().IsRegular() && info.Size() <= stat.Size() {
+		offset = info.Size()
+	}
+
+	dst, err := d.client.OpenFile(part, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return fmt.Errorf("创建目标文件失败: %w", err)
+	}
+	if offset > 0 {
+		_, err = dst.Seek(offset, io.SeekStart)
+		if err == nil {
+			_, err = src.Seek(offset, io.SeekStart)
+		}
+	} else {
+		err = dst.Truncate(0)
+	}
+	if err == nil {
+		_, err = io.Copy(dst, limitUpload(src))
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入文件内容失败: %w", err)
+	}
+
+	d.client.Chmod(part, stat.Mode().Perm())
+	if err := d.client.Chtimes(part, stat.ModTime(), stat.ModTime()); err != nil {
+		return fmt.Errorf("设置修改时间失败: %w", err)
+	}
+	if err := d.rename(part, remote); err != nil {
+		return fmt.Errorf("保存文件失败: %w", err)
+	}
+	return nil
+}
+
+// sftpPartPath 返回 remote 的临时文件路径: 同目录下的 .<文件名>.<大小>-<修改时间>.ftoz-part
+// 超出文件名长度限制时截断文件名并附加其哈希，同一源文件重新提交时得到相同的路径
+func sftpPartPath(remote string, size int64, mtime time.Time) string {
+	dir, name := path.Split(remote)
+	suffix := fmt.Sprintf(".%d-%d%s", size, mtime.Unix(), sftpPartSuffix)
+	if 1+len(name)+len(suffix) > sftpMaxNameBytes {
+		sum := sha256.Sum256([]byte(name))
+		hash := "~" + hex.EncodeToString(sum[:8])
+		name = truncateName(name, sftpMaxNameBytes-1-len(suffix)-len(hash)) + hash
+	}
+	return path.Join(dir, "."+name+suffix)
+}
+
+// rename 覆盖目标文件，服务器不支持 posix-rename 扩展时先删除目标文件
+func (d *SFTPDestination) rename(from, to string) error {
+	err := d.client.PosixRename(from, to)
+	if err == nil {
+		return nil
+	}
+	if _, statErr := d.client.Stat(to); statErr == nil {
+		d.client.Remove(to)
+	}
+	return d.client.Rename(from, to)
+}
+
+// Stat 查询文件或目录
+func (d *SFTPDestination) Stat(relPath string) (*DestEntry, error) {
+	info, err := d.client.Stat(d.remotePath(relPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DestEntry{Name: path.Base(relPath), IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List 列出目录，不含上传中的临时文件
+func (d *SFTPDestination) List(relDir string) ([]DestEntry, error) {
+	infos, err := d.client.ReadDir(d.remotePath(relDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DestEntry, 0, len(infos))
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), sftpPartSuffix) {
+			continue
+		}
+		entries = append(entries, DestEntry{Name: info.Name(), IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return entries, nil
+}
+
+// Free 通过 statvfs@openssh.com 扩展查询可用空间
+func (d *SFTPDestination) Free() (int64, error) {
+	vfs, err := d.client.StatVFS(d.root)
+	if err != nil {
+		return 0, err
+	}
+	return int64(vfs.Bavail * vfs.Frsize), nil
+}
+
+// remotePath 将目标根目录下的相对路径转换为服务器上的路径
+func (d *SFTPDestination) remotePath(relPath string) string {
+	return path.Join(d.root, relPath)
+}
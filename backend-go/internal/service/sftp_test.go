@@ -0,0 +1,204 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// noPosixRename 不支持 posix-rename@openssh.com 扩展的服务器，目标文件已存在时改名失败
+type noPosixRename struct {
+	sftp.FileCmder
+}
+
+// newTestSFTP 通过内存管道连接内存中的 SFTP 服务器，目标根目录为 /dst
+func newTestSFTP(t *testing.T, posixRename bool) *SFTPDestination {
+	t.Helper()
+	handlers := sftp.InMemHandler()
+	if !posixRename {
+		handlers.FileCmd = noPosixRename{handlers.FileCmd}
+	}
+
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, handlers)
+	go server.Serve()
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	if err := client.MkdirAll("/dst"); err != nil {
+		t.Fatal(err)
+	}
+	return &SFTPDestination{root: "/dst", client: client}
+}
+
+func writeRemote(t *testing.T, d *SFTPDestination, remote, content string) {
+	t.Helper()
+	f, err := d.client.Create(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readRemote(t *testing.T, d *SFTPDestination, remote string) string {
+	t.Helper()
+	f, err := d.client.Open(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSFTPPutFile(t *testing.T) {
+	d := newTestSFTP(t, true)
+	if err := d.Mkdir("a/b"); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := d.PutFile("a/b/file.txt", writeLocal(t, "hello sftp")); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	if got := readRemote(t, d, "/dst/a/b/file.txt"); got != "hello sftp" {
+		t.Fatalf("目标文件内容 = %q", got)
+	}
+
+	entries, err := d.List("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "file.txt" {
+		t.Fatalf("上传完成后临时文件应已改名: %+v", entries)
+	}
+}
+
+func TestSFTPPutFileResume(t *testing.T) {
+	d := newTestSFTP(t, true)
+	local := writeLocal(t, "0123456789abcdef")
+	stat, err := os.Stat(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 上次中断时已写入前 10 字节；续传时不再重新上传这部分，用不同的内容确认从临时文件大小处继续
+	part := sftpPartPath("/dst/file.bin", stat.Size(), stat.ModTime())
+	writeRemote(t, d, part, "XXXXXXXXXX")
+
+	if err := d.PutFile("file.bin", local); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	if got := readRemote(t, d, "/dst/file.bin"); got != "XXXXXXXXXXabcdef" {
+		t.Fatalf("目标文件内容 = %q, 应从临时文件大小处继续", got)
+	}
+	if _, err := d.client.Stat(part); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("临时文件应已改名: %v", err)
+	}
+}
+
+func TestSFTPPutFileRestartsStalePart(t *testing.T) {
+	d := newTestSFTP(t, true)
+	local := writeLocal(t, "short")
+	stat, err := os.Stat(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 临时文件比源文件大时无法续传，从头写入
+	part := sftpPartPath("/dst/file.bin", stat.Size(), stat.ModTime())
+	writeRemote(t, d, part, "much longer than the source")
+
+	if err := d.PutFile("file.bin", local); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	if got := readRemote(t, d, "/dst/file.bin"); got != "short" {
+		t.Fatalf("目标文件内容 = %q", got)
+	}
+}
+
+func TestSFTPPutFileOverwrite(t *testing.T) {
+	for _, posixRename := range []bool{true, false} {
+		d := newTestSFTP(t, posixRename)
+		writeRemote(t, d, "/dst/file.txt", "old content")
+
+		if err := d.PutFile("file.txt", writeLocal(t, "new")); err != nil {
+			t.Fatalf("posixRename=%v: PutFile: %v", posixRename, err)
+		}
+		if got := readRemote(t, d, "/dst/file.txt"); got != "new" {
+			t.Fatalf("posixRename=%v: 目标文件内容 = %q", posixRename, got)
+		}
+	}
+}
+
+func TestSFTPListSkipsPartFiles(t *testing.T) {
+	d := newTestSFTP(t, true)
+	writeRemote(t, d, "/dst/a.txt", "abc")
+	writeRemote(t, d, "/dst/.b.txt.5-1700000000"+sftpPartSuffix, "12")
+	if err := d.Mkdir("sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := d.List("")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	names := make(map[string]DestEntry)
+	for _, e := range entries {
+		names[e.Name] = e
+	}
+	if len(names) != 2 || names["a.txt"].Size != 3 || !names["sub"].IsDir {
+		t.Fatalf("List = %+v", entries)
+	}
+
+	if _, err := d.List("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("List missing = %v, want ErrNotFound", err)
+	}
+	if _, err := d.Stat("missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat missing = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSFTPPartPathLength(t *testing.T) {
+	mtime := time.Unix(1700000000, 0)
+	if got := sftpPartPath("/dst/a/file.txt", 42, mtime); got != "/dst/a/.file.txt.42-1700000000"+sftpPartSuffix {
+		t.Fatalf("sftpPartPath = %q", got)
+	}
+
+	long := strings.Repeat("文", 80) + ".mkv" // 244 字节
+	part := sftpPartPath("/dst/"+long, 1<<40, mtime)
+	name := path.Base(part)
+	if len(name) > sftpMaxNameBytes {
+		t.Fatalf("临时文件名 %d 字节，超出 %d", len(name), sftpMaxNameBytes)
+	}
+	if !strings.HasSuffix(name, ".1099511627776-1700000000"+sftpPartSuffix) || !strings.HasPrefix(name, ".文文") {
+		t.Fatalf("临时文件名 = %q", name)
+	}
+	if again := sftpPartPath("/dst/"+long, 1<<40, mtime); again != part {
+		t.Fatal("同一源文件的临时文件路径应保持一致以便续传")
+	}
+
+	other := strings.Repeat("文", 80) + ".mp4"
+	if sftpPartPath("/dst/"+other, 1<<40, mtime) == part {
+		t.Fatal("截断后的临时文件名不应与其他文件相同")
+	}
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"ftoz/internal/config"
+	"ftoz/internal/model"
+)
+
+const (
+	// DefaultSourceDir 默认迁移目录 (uid 1000 的个人空间)
+	DefaultSourceDir = "/vol1/1000"
+
+	// 源目录类型
+	SourceTypePersonal = "personal"
+	SourceTypeTeam     = "team"
+	SourceTypeVolume   = "volume"
+	SourceTypeCustom   = "custom"
+)
+
+var volumePattern = regexp.MustCompile(`^vol\d+$`)
+
+// SourceRegistry 迁移源目录注册表
+// 自动发现 FNOS 存储卷 (/volN)、各用户个人空间 (/volN/<uid>) 与团队空间 (/volN/@team)，
+// 并合并配置文件中声明的自定义目录
+type SourceRegistry struct {
+	root    string
+	custom  []config.SourceConfig
+	sources []model.SourceInfo
+}
+
+// NewSourceRegistry 创建源目录注册表
+func NewSourceRegistry(cfg *config.Config) *SourceRegistry {
+	r := &SourceRegistry{root: "/"}
+	if cfg != nil {
+		r.custom = cfg.Sources
+	}
+	return r
+}
+
+// List 返回所有可迁移的源目录
+func (r *SourceRegistry) List() []model.SourceInfo {
+	if r.sources == nil {
+		r.sources = r.discover()
+	}
+	return r.sources
+}
+
+// Resolve 根据 source (或兼容参数 space) 解析源目录，未知的源返回 nil
+func (r *SourceRegistry) Resolve(sourceType, space string) *model.SourceInfo {
+	// 兼容 source 和 space 参数
+	st := strings.TrimSpace(sourceType)
+	if st == "" {
+		st = strings.TrimSpace(space)
+	}
+
+	switch st {
+	case "":
+		// 默认源目录
+		envDir := os.Getenv("SOURCE_DIR")
+		if envDir == "" {
+			envDir = DefaultSourceDir
+		}
+		if info := r.findByDir(envDir); info != nil {
+			return info
+		}
+		return &model.SourceInfo{ID: SourceTypeCustom, Type: SourceTypeCustom, Label: "自定义目录", Dir: envDir}
+	case SourceTypePersonal:
+		// 兼容旧参数：默认用户的个人空间
+		if info := r.findByDir(DefaultSourceDir); info != nil {
+			return info
+		}
+		return &model.SourceInfo{ID: SourceTypePersonal, Type: SourceTypePersonal, Label: "个人空间", Dir: DefaultSourceDir}
+	case SourceTypeTeam:
+		// 兼容旧参数：第一个存储卷的团队空间
+		if info := r.findByDir("/vol1/@team"); info != nil {
+			return info
+		}
+		return &model.SourceInfo{ID: SourceTypeTeam, Type: SourceTypeTeam, Label: "团队空间", Dir: "/vol1/@team"}
+	}
+
+	for _, info := range r.List() {
+		if info.ID == st {
+			return &info
+		}
+	}
+	return nil
+}
+
+// FillUsage 统计源目录占用空间及所在存储卷的容量
+func (r *SourceRegistry) FillUsage(info *model.SourceInfo, withSize bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(info.Dir, &st); err == nil {
+		info.Total = int64(st.Blocks) * int64(st.Bsize)
+		info.Free = int64(st.Bavail) * int64(st.Bsize)
+	}
+	if withSize {
+		info.Size = dirSize(info.Dir)
+	}
+}
+
+func (r *SourceRegistry) findByDir(dir string) *model.SourceInfo {
+	dir = filepath.Clean(dir)
+	for _, info := range r.List() {
+		if info.Dir == dir {
+			return &info
+		}
+	}
+	return nil
+}
+
+// discover 扫描存储卷并生成源目录列表
+func (r *SourceRegistry) discover() []model.SourceInfo {
+	sources := []model.SourceInfo{}
+	users := r.loadUsers()
+	mounts := r.loadMounts()
+
+	entries, _ := os.ReadDir(r.root)
+	var volumes []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !volumePattern.MatchString(entry.Name()) {
+			continue
+		}
+		volDir := filepath.Join(r.root, entry.Name())
+		// 无法读取挂载表时不做过滤
+		if mounts != nil && !mounts[volDir] {
+			continue
+		}
+		volumes = append(volumes, entry.Name())
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumeIndex(volumes[i]) < volumeIndex(volumes[j])
+	})
+
+	for _, vol := range volumes {
+		volDir := filepath.Join(r.root, vol)
+		sources = append(sources, model.SourceInfo{
+			ID:    vol,
+			Type:  SourceTypeVolume,
+			Label: "存储卷 " + vol,
+			Dir:   volDir,
+		})
+
+		children, err := os.ReadDir(volDir)
+		if err != nil {
+			continue
+		}
+		for _, child := range children {
+			if !child.IsDir() {
+				continue
+			}
+			name := child.Name()
+
+			if name == "@team" {
+				sources = append(sources, model.SourceInfo{
+					ID:    "team-" + vol,
+					Type:  SourceTypeTeam,
+					Label: "团队空间 (" + vol + ")",
+					Dir:   filepath.Join(volDir, name),
+				})
+				continue
+			}
+
+			// 个人空间目录以用户 uid 命名
+			uid, err := strconv.Atoi(name)
+			if err != nil || uid < 1000 {
+				continue
+			}
+			owner := users[uid]
+			if owner == "" {
+				owner = name
+			}
+			id := "home-" + owner
+			if vol != "vol1" {
+				id += "-" + vol
+			}
+			sources = append(sources, model.SourceInfo{
+				ID:    id,
+				Type:  SourceTypePersonal,
+				Label: "个人空间 (" + owner + ")",
+				Dir:   filepath.Join(volDir, name),
+				Owner: owner,
+			})
+		}
+	}
+
+	// 配置文件中的自定义目录
+	for _, c := range r.custom {
+		if c.ID == "" || c.Dir == "" {
+			continue
+		}
+		label := c.Label
+		if label == "" {
+			label = c.ID
+		}
+		sources = append(sources, model.SourceInfo{
+			ID:    c.ID,
+			Type:  SourceTypeCustom,
+			Label: label,
+			Dir:   filepath.Clean(c.Dir),
+		})
+	}
+
+	return sources
+}
+
+// loadUsers 读取 /etc/passwd，返回 uid 到用户名的映射
+func (r *SourceRegistry) loadUsers() map[int]string {
+	users := make(map[int]string)
+
+	f, err := os.Open(filepath.Join(r.root, "etc/passwd"))
+	if err != nil {
+		return users
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		users[uid] = fields[0]
+	}
+	return users
+}
+
+// loadMounts 读取挂载表，返回挂载点集合
+func (r *SourceRegistry) loadMounts() map[string]bool {
+	f, err := os.Open(filepath.Join(r.root, "proc/mounts"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	mounts := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 {
+			mounts[fields[1]] = true
+		}
+	}
+	return mounts
+}
+
+// volumeIndex 返回存储卷序号，用于排序
+func volumeIndex(name string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(name, "vol"))
+	return n
+}
+
+// dirSize 统计目录下普通文件的总大小，忽略无法读取的条目
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
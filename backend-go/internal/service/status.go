@@ -0,0 +1,46 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ftoz/internal/model"
+)
+
+// StatusDir 任务状态文件目录
+const StatusDir = "/tmp"
+
+// statusFilePath 返回任务状态文件路径
+func statusFilePath(taskId string) string {
+	return filepath.Join(StatusDir, fmt.Sprintf("ftoz-migrate-%s.json", taskId))
+}
+
+// WriteTaskStatus 写入任务状态文件 (先写临时文件再重命名，避免读到半截内容)
+func WriteTaskStatus(status *model.TaskStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	statusFile := statusFilePath(status.TaskID)
+	tmpFile := statusFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, statusFile)
+}
+
+// ReadTaskStatus 读取任务状态文件
+func ReadTaskStatus(taskId string) (*model.TaskStatus, error) {
+	data, err := os.ReadFile(statusFilePath(taskId))
+	if err != nil {
+		return nil, err
+	}
+	var status model.TaskStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// taskLogMu 同一进程内多个协程写日志时保证每行完整
+var taskLogMu sync.Mutex
+
+// logFilePath 返回任务日志文件路径
+func logFilePath(taskId string) string {
+	return filepath.Join(StatusDir, fmt.Sprintf("ftoz-migrate-%s.log", taskId))
+}
+
+// AppendTaskLog 追加一行任务日志，行首为本地时间
+func AppendTaskLog(taskId, msg string) error {
+	line := time.Now().Format("2006-01-02 15:04:05") + " " + strings.ReplaceAll(msg, "\n", " ") + "\n"
+
+	taskLogMu.Lock()
+	defer taskLogMu.Unlock()
+
+	f, err := os.OpenFile(logFilePath(taskId), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(line)
+	return err
+}
+
+// ReadTaskLog 读取任务日志的最后 n 行，n <= 0 时返回全部
+// 任务尚未写过日志时返回空列表
+func ReadTaskLog(taskId string, n int) ([]string, error) {
+	f, err := os.Open(logFilePath(taskId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if n > 0 && len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
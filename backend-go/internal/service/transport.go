@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"ftoz/internal/model"
+)
+
+// 传输层默认超时
+const (
+	defaultDialTimeout = 10 * time.Second
+	defaultTLSTimeout  = 10 * time.Second
+	defaultIdleTimeout = 90 * time.Second
+)
+
+// ProxyDirect 代理设置为该值时不使用代理 (忽略环境变量)
+const ProxyDirect = "direct"
+
+// MergeTransportOptions 以请求中的选项覆盖配置文件中的默认选项
+// 字符串、列表和超时只覆盖已设置的字段；开关项任一方开启即开启
+func MergeTransportOptions(base model.TransportOptions, override *model.TransportOptions) model.TransportOptions {
+	if override == nil {
+		return base
+	}
+	merged := base
+	if override.CAFile != "" {
+		merged.CAFile = override.CAFile
+	}
+	if override.CACert != "" {
+		merged.CACert = override.CACert
+	}
+	if len(override.Pins) > 0 {
+		merged.Pins = override.Pins
+	}
+	if override.Proxy != "" {
+		merged.Proxy = override.Proxy
+	}
+	merged.InsecureSkipVerify = base.InsecureSkipVerify || override.InsecureSkipVerify
+	merged.HTTP2 = base.HTTP2 || override.HTTP2
+	if override.DialTimeout > 0 {
+		merged.DialTimeout = override.DialTimeout
+	}
+	if override.TLSTimeout > 0 {
+		merged.TLSTimeout = override.TLSTimeout
+	}
+	if override.ResponseTimeout > 0 {
+		merged.ResponseTimeout = override.ResponseTimeout
+	}
+	if override.IdleTimeout > 0 {
+		merged.IdleTimeout = override.IdleTimeout
+	}
+	return merged
+}
+
+// NewHTTPTransport 按选项创建 HTTP 传输层
+// conns 为预计同时进行的传输数，空闲连接池按此保留连接，避免并发上传时反复建立连接
+func NewHTTPTransport(opts model.TransportOptions, conns int) (*http.Transport, error) {
+	if opts.DialTimeout < 0 || opts.TLSTimeout < 0 || opts.ResponseTimeout < 0 || opts.IdleTimeout < 0 {
+		return nil, fmt.Errorf("传输超时设置无效")
+	}
+	if conns <= 0 {
+		conns = 1
+	}
+
+	proxy, err := proxyFunc(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   secondsOr(opts.DialTimeout, defaultDialTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	// 额外保留几个连接给上传期间的目录创建、列目录等接口调用
+	idle := conns + 2
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   secondsOr(opts.TLSTimeout, defaultTLSTimeout),
+		ResponseHeaderTimeout: time.Duration(opts.ResponseTimeout) * time.Second,
+		IdleConnTimeout:       secondsOr(opts.IdleTimeout, defaultIdleTimeout),
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          idle,
+		MaxIdleConnsPerHost:   idle,
+		ForceAttemptHTTP2:     opts.HTTP2,
+	}, nil
+}
+
+// secondsOr 将秒数转换为时间，未设置时返回默认值
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// proxyFunc 解析代理设置
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case ProxyDirect:
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("代理地址无效: %s", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// tlsConfig 根据 CA、指纹和校验开关生成 TLS 配置
+// 设置指纹时不再按系统 CA 校验证书链和域名，适用于自签名证书：服务端证书的公钥与指纹匹配即信任；
+// 指纹为签发证书的 CA 时，须能以该 CA 为根验证服务端证书的签名链
+func tlsConfig(opts model.TransportOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CAFile != "" || opts.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if opts.CAFile != "" {
+			data, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("CA 证书文件中没有有效的证书: %s", opts.CAFile)
+			}
+		}
+		if opts.CACert != "" && !pool.AppendCertsFromPEM([]byte(opts.CACert)) {
+			return nil, fmt.Errorf("caCert 中没有有效的证书")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(opts.Pins) > 0 {
+		pins := make([][]byte, 0, len(opts.Pins))
+		for _, p := range opts.Pins {
+			pin, err := parsePin(p)
+			if err != nil {
+				return nil, err
+			}
+			pins = append(pins, pin)
+		}
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs.PeerCertificates, pins)
+		}
+	}
+	return cfg, nil
+}
+
+// verifyPins 校验服务端证书与指纹匹配
+// 服务端只能证明持有证书链首个证书 (服务端证书) 的私钥，链中其他证书可任意附带，
+// 因此匹配的是其他证书时以其为根验证服务端证书的签名链
+func verifyPins(certs []*x509.Certificate, pins [][]byte) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("服务端未提供证书")
+	}
+	matches := func(cert *x509.Certificate) bool {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	leaf := certs[0]
+	if matches(leaf) {
+		return nil
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	pinned := false
+	for _, cert := range certs[1:] {
+		if matches(cert) {
+			roots.AddCert(cert)
+			pinned = true
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+	if !pinned {
+		return fmt.Errorf("服务端证书与指纹不匹配")
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("服务端证书不是由指纹对应的证书签发: %w", err)
+	}
+	return nil
+}
+
+// parsePin 解析公钥指纹，支持 sha256/<base64>、base64 和十六进制 (可带冒号)
+func parsePin(pin string) ([]byte, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	if b, err := hex.DecodeString(strings.ReplaceAll(s, ":", "")); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return nil, fmt.Errorf("证书指纹无效: %s", pin)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCert 生成证书，parent 为 nil 时自签名
+func newTestCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func pinOf(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+func TestVerifyPins(t *testing.T) {
+	ca, caKey := newTestCert(t, "ca", true, nil, nil)
+	leaf, _ := newTestCert(t, "nas.local", false, ca, caKey)
+	selfSigned, _ := newTestCert(t, "nas.local", false, nil, nil)
+	forged, _ := newTestCert(t, "attacker", false, nil, nil)
+
+	tests := []struct {
+		name  string
+		certs []*x509.Certificate
+		pin   *x509.Certificate
+		ok    bool
+	}{
+		{"自签名服务端证书", []*x509.Certificate{selfSigned}, selfSigned, true},
+		{"服务端证书", []*x509.Certificate{leaf, ca}, leaf, true},
+		{"签发服务端证书的 CA", []*x509.Certificate{leaf, ca}, ca, true},
+		{"附带与服务端证书无关的 CA", []*x509.Certificate{forged, ca}, ca, false},
+		{"不匹配", []*x509.Certificate{leaf, ca}, selfSigned, false},
+		{"空证书链", nil, ca, false},
+	}
+	for _, tt := range tests {
+		err := verifyPins(tt.certs, [][]byte{pinOf(tt.pin)})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: verifyPins = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	cert, _ := newTestCert(t, "nas.local", false, nil, nil)
+	want := pinOf(cert)
+	for _, s := range []string{
+		"sha256/" + base64.StdEncoding.EncodeToString(want),
+		base64.StdEncoding.EncodeToString(want),
+		strings.ToUpper(hex.EncodeToString(want)),
+	} {
+		got, err := parsePin(s)
+		if err != nil || string(got) != string(want) {
+			t.Errorf("parsePin(%q) = %x, %v", s, got, err)
+		}
+	}
+	if _, err := parsePin("not-a-pin"); err == nil {
+		t.Error("parsePin 应拒绝无效指纹")
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStallTimeout 默认的上传停滞判定时间
+const DefaultStallTimeout = 2 * time.Minute
+
+// StallTimeoutDisabled 请求中的 stallTimeout 取该值时不检测停滞 (0 表示使用默认值)
+const StallTimeoutDisabled = -1
+
+// ErrStalled 上传停滞：超过停滞判定时间没有发送数据或收到响应
+var ErrStalled = errors.New("上传停滞")
+
+// stallTimeout 上传停滞判定时间，由 worker 进程设置，不大于 0 时不检测
+var stallTimeout = DefaultStallTimeout
+
+// SetStallTimeout 设置各 HTTP 目标端上传文件时的停滞判定时间，d 不大于 0 时不检测
+func SetStallTimeout(d time.Duration) {
+	stallTimeout = d
+}
+
+// stallWatch 上传停滞检测
+// 请求体每次被读取时记录进度；请求体读完后等待响应的时间同样计入，超时即取消请求
+type stallWatch struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	last   atomic.Int64 // 最后一次有进度的时间 (UnixNano)
+	done   chan struct{}
+}
+
+// watchStall 开始检测，返回的 ctx 在停滞时以 ErrStalled 取消；不检测时返回 nil
+func watchStall(parent context.Context) *stallWatch {
+	if stallTimeout <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	w := &stallWatch{ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	w.touch()
+
+	go func() {
+		ticker := time.NewTicker(stallTimeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, w.last.Load())) > stallTimeout {
+					cancel(fmt.Errorf("%w: %s 内没有数据传输", ErrStalled, stallTimeout))
+					return
+				}
+			}
+		}
+	}()
+	return w
+}
+
+func (w *stallWatch) touch() {
+	w.last.Store(time.Now().UnixNano())
+}
+
+// reader 包装请求体，每次读取都视为有进度
+func (w *stallWatch) reader(r io.Reader) io.Reader {
+	if w == nil {
+		return r
+	}
+	return &stallReader{r: r, w: w}
+}
+
+// context 返回请求使用的 ctx
+func (w *stallWatch) context() context.Context {
+	if w == nil {
+		return context.Background()
+	}
+	return w.ctx
+}
+
+// err 请求失败时返回停滞原因，未停滞时返回 err 本身
+func (w *stallWatch) err(err error) error {
+	if w != nil && errors.Is(context.Cause(w.ctx), ErrStalled) {
+		return context.Cause(w.ctx)
+	}
+	return err
+}
+
+// stop 结束检测
+func (w *stallWatch) stop() {
+	if w == nil {
+		return
+	}
+	close(w.done)
+	w.cancel(nil)
+}
+
+// body 包装响应体，关闭响应体时结束检测
+func (w *stallWatch) body(rc io.ReadCloser) io.ReadCloser {
+	if w == nil {
+		return rc
+	}
+	w.touch()
+	return &stallBody{ReadCloser: rc, w: w}
+}
+
+type stallBody struct {
+	io.ReadCloser
+	w *stallWatch
+}
+
+func (b *stallBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.w.stop()
+	return err
+}
+
+type stallReader struct {
+	r io.Reader
+	w *stallWatch
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.w.touch()
+	return n, err
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DestTypeWebDAV WebDAV 目标端
+const DestTypeWebDAV = "webdav"
+
+const (
+	propfindStat = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+	propfindQuota = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:quota-available-bytes/></d:prop></d:propfind>`
+	proppatchMtime = `<?xml version="1.0" encoding="utf-8"?>
+<d:propertyupdate xmlns:d="DAV:"><d:set><d:prop><d:getlastmodified>%s</d:getlastmodified></d:prop></d:set></d:propertyupdate>`
+)
+
+// WebDAVDestination WebDAV 目标端
+// 目录通过 MKCOL 创建，文件通过 PUT 流式上传，文件信息通过 PROPFIND 查询。
+// 修改时间优先通过 X-OC-Mtime 请求头设置 (Nextcloud/ownCloud/rclone)，
+// 否则尝试 PROPPATCH getlastmodified，服务器均不支持时不再设置
+type WebDAVDestination struct {
+	client   *http.Client // PROPFIND/MKCOL/PROPPATCH 等元数据请求
+	transfer *http.Client // 文件上传
+	base     *url.URL     // 服务器地址，如 https://dav.example.com/remote.php/dav/files/alice
+	root     string       // 目标根目录 (相对服务器地址)
+	username string
+	password string
+
+	mu               sync.Mutex
+	mtimeUnsupported bool
+}
+
+// davMultistatus PROPFIND 响应
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength  string `xml:"getcontentlength"`
+				LastModified   string `xml:"getlastmodified"`
+				QuotaAvailable string `xml:"quota-available-bytes"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// NewWebDAVDestination 创建 WebDAV 目标端，root 为服务器上的目标目录
+func NewWebDAVDestination(baseURL, username, password, root string) (*WebDAVDestination, error) {
+	base, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("WebDAV 地址无效: %s", baseURL)
+	}
+	return &WebDAVDestination{
+		client: &http.Client{Timeout: 30 * time.Second},
+		// 上传不设置整体超时，避免大文件上传失败
+		transfer: &http.Client{},
+		base:     base,
+		root:     strings.Trim(root, "/"),
+		username: username,
+		password: password,
+	}, nil
+}
+
+// Authenticate 校验账号并逐级创建目标根目录
+func (d *WebDAVDestination) Authenticate() error {
+	resp, err := d.do("PROPFIND", "", strings.NewReader(propfindStat), map[string]string{"Depth": "0"})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("WebDAV 用户名或密码错误")
+	case resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("WebDAV 无访问权限")
+	case resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound:
+		return fmt.Errorf("连接 WebDAV 失败(%d)", resp.StatusCode)
+	}
+
+	dir := ""
+	for _, part := range strings.Split(d.root, "/") {
+		if part == "" {
+			continue
+		}
+		dir = path.Join(dir, part)
+		if err := d.mkcol(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mkdir 创建目录，目录已存在时服务器返回 405
+func (d *WebDAVDestination) Mkdir(relDir string) error {
+	return d.mkcol(d.remotePath(relDir))
+}
+
+// SetDirTime 通过 PROPPATCH 设置目录修改时间
+func (d *WebDAVDestination) SetDirTime(relDir string, mtime time.Time) error {
+	if !d.patchMtime(d.remotePath(relDir), mtime) {
+		return ErrDirTimeUnsupported
+	}
+	return nil
+}
+
+// PutFile 流式上传文件并尽量保留修改时间
+func (d *WebDAVDestination) PutFile(relPath, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("获取文件信息失败: %w", err)
+	}
+
+	remote := d.remotePath(relPath)
+	watch := watchStall(context.Background())
+	defer watch.stop()
+
+	req, err := d.newRequest("PUT", remote, limitUpload(watch.reader(file)))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(watch.context())
+	req.ContentLength = stat.Size()
+	if stat.Size() == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-OC-Mtime", strconv.FormatInt(stat.ModTime().Unix(), 10))
+
+	resp, err := d.transfer.Do(req)
+	if err != nil {
+		return fmt.Errorf("上传请求失败: %w", watch.err(err))
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("上传失败(%d)", resp.StatusCode)
+	}
+
+	if resp.Header.Get("X-OC-Mtime") != "accepted" {
+		d.setMtime(remote, stat.ModTime())
+	}
+	return nil
+}
+
+// Stat 通过 PROPFIND (Depth: 0) 查询文件或目录
+func (d *WebDAVDestination) Stat(relPath string) (*DestEntry, error) {
+	self, _, err := d.propfind(d.remotePath(relPath), "0")
+	if err != nil {
+		return nil, err
+	}
+	if self == nil {
+		return nil, ErrNotFound
+	}
+	self.Name = path.Base(relPath)
+	if relPath == "" {
+		self.Name = ""
+	}
+	return self, nil
+}
+
+// List 通过 PROPFIND (Depth: 1) 列出目录
+func (d *WebDAVDestination) List(relDir string) ([]DestEntry, error) {
+	_, entries, err := d.propfind(d.remotePath(relDir), "1")
+	return entries, err
+}
+
+// Free 通过 quota-available-bytes (RFC 4331) 查询可用空间
+func (d *WebDAVDestination) Free() (int64, error) {
+	resp, err := d.do("PROPFIND", d.root, strings.NewReader(propfindQuota), map[string]string{"Depth": "0"})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return 0, fmt.Errorf("查询可用空间失败(%d)", resp.StatusCode)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return 0, fmt.Errorf("解析 PROPFIND 响应失败: %w", err)
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if free, err := strconv.ParseInt(strings.TrimSpace(ps.Prop.QuotaAvailable), 10, 64); err == nil && free >= 0 {
+				return free, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("WebDAV 服务器未提供可用空间")
+}
+
+// propfind 查询 remote 自身及其子条目 (depth 为 1 时)
+func (d *WebDAVDestination) propfind(remote, depth string) (*DestEntry, []DestEntry, error) {
+	resp, err := d.do("PROPFIND", remote, strings.NewReader(propfindStat), map[string]string{"Depth": depth})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, nil, fmt.Errorf("PROPFIND 失败(%d)", resp.StatusCode)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, nil, fmt.Errorf("解析 PROPFIND 响应失败: %w", err)
+	}
+
+	var self *DestEntry
+	var children []DestEntry
+	selfPath := strings.Trim(d.urlFor(remote).Path, "/")
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		hrefPath := strings.Trim(href.Path, "/")
+
+		entry := DestEntry{Name: path.Base(hrefPath)}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200") {
+				continue
+			}
+			entry.IsDir = ps.Prop.ResourceType.Collection != nil
+			entry.Size, _ = strconv.ParseInt(strings.TrimSpace(ps.Prop.ContentLength), 10, 64)
+			if t, err := http.ParseTime(strings.TrimSpace(ps.Prop.LastModified)); err == nil {
+				entry.ModTime = t
+			}
+		}
+		if hrefPath == selfPath {
+			self = &entry
+		} else {
+			children = append(children, entry)
+		}
+	}
+	return self, children, nil
+}
+
+// mkcol 创建 remote 目录
+func (d *WebDAVDestination) mkcol(remote string) error {
+	resp, err := d.do("MKCOL", remote, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// 405: 目录已存在
+	if resp.StatusCode < 300 || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil
+	}
+	return fmt.Errorf("创建目录 %s 失败(%d)", remote, resp.StatusCode)
+}
+
+// setMtime 通过 PROPPATCH 设置修改时间，服务器不支持时不再尝试
+func (d *WebDAVDestination) setMtime(remote string, mtime time.Time) {
+	d.mu.Lock()
+	unsupported := d.mtimeUnsupported
+	d.mu.Unlock()
+	if unsupported {
+		return
+	}
+	if !d.patchMtime(remote, mtime) {
+		d.mu.Lock()
+		d.mtimeUnsupported = true
+		d.mu.Unlock()
+	}
+}
+
+// patchMtime 发送 PROPPATCH getlastmodified，返回服务器是否接受
+func (d *WebDAVDestination) patchMtime(remote string, mtime time.Time) bool {
+	body := fmt.Sprintf(proppatchMtime, mtime.UTC().Format(http.TimeFormat))
+	resp, err := d.do("PROPPATCH", remote, strings.NewReader(body), nil)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	// 207 响应中每个属性的状态均须为 200
+	ok := resp.StatusCode < 300
+	if resp.StatusCode == http.StatusMultiStatus {
+		var ms davMultistatus
+		if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			ok = false
+		}
+		for _, r := range ms.Responses {
+			for _, ps := range r.Propstat {
+				if !strings.Contains(ps.Status, " 200") {
+					ok = false
+				}
+			}
+		}
+	}
+	return ok
+}
+
+func (d *WebDAVDestination) do(method, remote string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := d.newRequest(method, remote, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && method != "PUT" {
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("WebDAV %s 请求失败: %w", method, err)
+	}
+	return resp, nil
+}
+
+func (d *WebDAVDestination) newRequest(method, remote string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, d.urlFor(remote).String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("创建 WebDAV 请求失败: %w", err)
+	}
+	if d.username != "" {
+		req.SetBasicAuth(d.username, d.password)
+	}
+	return req, nil
+}
+
+// urlFor 返回服务器上 remote 路径的完整地址
+func (d *WebDAVDestination) urlFor(remote string) *url.URL {
+	u := *d.base
+	u.RawPath = ""
+	u.Path = strings.TrimRight(d.base.Path, "/") + "/" + strings.Trim(remote, "/")
+	return &u
+}
+
+// remotePath 将目标根目录下的相对路径转换为服务器上的路径
+func (d *WebDAVDestination) remotePath(relPath string) string {
+	return path.Join(d.root, relPath)
+}
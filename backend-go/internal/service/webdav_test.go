@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// newTestWebDAV 启动以临时目录为存储的 WebDAV 服务器，返回目标端和服务器上的存储目录
+func newTestWebDAV(t *testing.T, root string) (*WebDAVDestination, string) {
+	t.Helper()
+	dir := t.TempDir()
+	handler := &webdav.Handler{FileSystem: webdav.Dir(dir), LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	dest, err := NewWebDAVDestination(srv.URL+"/", "alice", "secret", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dest, dir
+}
+
+func writeLocal(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "src")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestWebDAVAuthenticateCreatesRoot(t *testing.T) {
+	dest, dir := newTestWebDAV(t, "/backup/photos/")
+	if err := dest.Authenticate(); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "backup", "photos")); err != nil || !info.IsDir() {
+		t.Fatalf("目标根目录未创建: %v", err)
+	}
+	// 目录已存在时再次校验不报错
+	if err := dest.Authenticate(); err != nil {
+		t.Fatalf("Authenticate again: %v", err)
+	}
+}
+
+func TestWebDAVAuthenticateWrongPassword(t *testing.T) {
+	dest, _ := newTestWebDAV(t, "backup")
+	dest.password = "wrong"
+	err := dest.Authenticate()
+	if err == nil || !strings.Contains(err.Error(), "用户名或密码错误") {
+		t.Fatalf("Authenticate = %v, want 用户名或密码错误", err)
+	}
+}
+
+func TestWebDAVPutFileOverwrite(t *testing.T) {
+	dest, dir := newTestWebDAV(t, "backup")
+	if err := dest.Authenticate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dest.Mkdir("a b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dest.PutFile("a b/100%.txt", writeLocal(t, "first version")); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	if err := dest.PutFile("a b/100%.txt", writeLocal(t, "second")); err != nil {
+		t.Fatalf("PutFile overwrite: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "backup", "a b", "100%.txt"))
+	if err != nil || string(data) != "second" {
+		t.Fatalf("目标文件内容 = %q, %v", data, err)
+	}
+
+	entry, err := dest.Stat("a b/100%.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if entry.Name != "100%.txt" || entry.IsDir || entry.Size != int64(len("second")) {
+		t.Fatalf("Stat = %+v", entry)
+	}
+}
+
+func TestWebDAVPutEmptyFile(t *testing.T) {
+	dest, dir := newTestWebDAV(t, "")
+	if err := dest.PutFile("empty", writeLocal(t, "")); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "empty")); err != nil || info.Size() != 0 {
+		t.Fatalf("空文件未上传: %v", err)
+	}
+}
+
+func TestWebDAVStatNotFound(t *testing.T) {
+	dest, _ := newTestWebDAV(t, "")
+	if _, err := dest.Stat("missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat = %v, want ErrNotFound", err)
+	}
+	if _, err := dest.List("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("List = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWebDAVList(t *testing.T) {
+	dest, _ := newTestWebDAV(t, "backup")
+	if err := dest.Authenticate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dest.Mkdir("sub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dest.PutFile("a.txt", writeLocal(t, "abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dest.PutFile("中文.txt", writeLocal(t, "12345")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := dest.List("")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	want := []DestEntry{
+		{Name: "a.txt", Size: 3},
+		{Name: "sub", IsDir: true},
+		{Name: "中文.txt", Size: 5},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("List = %+v", entries)
+	}
+	for i, e := range entries {
+		if e.Name != want[i].Name || e.IsDir != want[i].IsDir || (!e.IsDir && e.Size != want[i].Size) {
+			t.Errorf("entries[%d] = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestWebDAVMtimeUnsupported(t *testing.T) {
+	// x/net/webdav 不允许通过 PROPPATCH 修改 getlastmodified
+	dest, _ := newTestWebDAV(t, "")
+	if err := dest.Mkdir("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dest.SetDirTime("dir", time.Unix(1600000000, 0)); !errors.Is(err, ErrDirTimeUnsupported) {
+		t.Fatalf("SetDirTime = %v, want ErrDirTimeUnsupported", err)
+	}
+
+	if err := dest.PutFile("a.txt", writeLocal(t, "abc")); err != nil {
+		t.Fatal(err)
+	}
+	if !dest.mtimeUnsupported {
+		t.Fatal("服务器不支持设置修改时间后应不再尝试")
+	}
+}
+
+func TestWebDAVMetadataClientTimeout(t *testing.T) {
+	dest, _ := newTestWebDAV(t, "")
+	if dest.client.Timeout == 0 {
+		t.Error("元数据请求应设置超时")
+	}
+	if dest.transfer.Timeout != 0 {
+		t.Error("文件上传不应设置整体超时")
+	}
+}
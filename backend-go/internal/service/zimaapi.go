@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// 服务端 API 版本
+	APIProfileAuto   = "auto"   // 自动探测
+	APIProfileZimaOS = "zimaos" // ZimaOS 1.x 及以后的 /v2_1/files 接口
+	APIProfileCasaOS = "casaos" // CasaOS 0.4.x 的 /v1 文件接口 (CasaOS v2 接口仍使用该组文件接口)
+)
+
+// apiProfile 一种服务端版本的文件接口路径
+// 路径为空表示该版本不支持对应操作
+type apiProfile struct {
+	Name  string
+	Label string
+
+	Login       string // POST {username, password}
+	List        string // GET ?path&index&size，返回 {content, total}
+	Mkdir       string // POST {path}
+	FolderInfo  string // GET ?path，返回目录大小和可用空间
+	Rename      string // ZimaOS: PUT ?path {new_path}；CasaOS: PUT {old_path, new_path}
+	DirModTime  string // PUT ?path {new_path, mod_time}，通过目录更新接口设置修改时间，服务端可能忽略
+	Upload      string // POST multipart，整个文件一次上传
+	ChunkUpload string // 分片上传
+	Download    string // GET ?path
+	Delete      string // DELETE 路径数组
+	Health      string // GET 健康检查
+
+	UploadModTime bool // 上传时可设置文件修改时间，否则列目录得到的是上传时间
+
+	// 服务端任务和远程连接 (拉取模式、打包解压、去重复制)
+	Tasks          string
+	TaskImport     string
+	TaskDecompress string
+	TaskCopy       string
+	Connect        string
+}
+
+var apiProfiles = map[string]*apiProfile{
+	APIProfileZimaOS: {
+		Name:           APIProfileZimaOS,
+		Label:          "ZimaOS (v2_1)",
+		Login:          "/v1/users/login",
+		List:           "/v2_1/files/file",
+		Mkdir:          "/v2_1/files/folder",
+		FolderInfo:     "/v2_1/files/folder",
+		Rename:         "/v2_1/files/folder",
+		DirModTime:     "/v2_1/files/folder",
+		Upload:         "/v2_1/files/file/uploadV2",
+		ChunkUpload:    "/v2_1/files/file/upload",
+		Download:       "/v2_1/files/file/download",
+		Delete:         "/v2_1/files/file",
+		Health:         "/v2_1/files/health/dependencies",
+		Tasks:          "/v2_1/files/tasks",
+		TaskImport:     "/v2_1/files/task/import",
+		TaskDecompress: "/v2_1/files/task/decompress",
+		TaskCopy:       "/v2_1/files/task/copy",
+		Connect:        "/v2_1/files/connect",
+		UploadModTime:  true,
+	},
+	APIProfileCasaOS: {
+		Name:        APIProfileCasaOS,
+		Label:       "CasaOS (v1)",
+		Login:       "/v1/users/login",
+		List:        "/v1/folder",
+		Mkdir:       "/v1/folder",
+		Rename:      "/v1/folder/name",
+		Upload:      "/v1/file/upload",
+		ChunkUpload: "/v1/file/upload",
+		Download:    "/v1/file/download",
+		Delete:      "/v1/batch",
+	},
+}
+
+// probeOrder 自动探测时依次尝试的版本，较新的版本在前
+var probeOrder = []string{APIProfileZimaOS, APIProfileCasaOS}
+
+// ValidAPIProfile 检查 API 版本名称是否有效，空字符串表示自动探测
+func ValidAPIProfile(name string) bool {
+	if name == "" || name == APIProfileAuto {
+		return true
+	}
+	_, ok := apiProfiles[name]
+	return ok
+}
+
+// SetAPIProfile 指定服务端 API 版本，auto 或空字符串表示首次请求时自动探测
+func (c *ZimaOSClient) SetAPIProfile(name string) error {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+
+	if name == "" || name == APIProfileAuto {
+		c.profile = nil
+		c.profileFixed = false
+		return nil
+	}
+	p, ok := apiProfiles[name]
+	if !ok {
+		return fmt.Errorf("不支持的 API 版本: %s", name)
+	}
+	c.profile = p
+	c.profileFixed = true
+	return nil
+}
+
+// APIProfile 返回当前使用的 API 版本名称，尚未探测时为空
+func (c *ZimaOSClient) APIProfile() string {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+	if c.profile == nil {
+		return ""
+	}
+	return c.profile.Name
+}
+
+// api 返回 baseURL 对应的接口版本，首次调用时探测，之后复用结果
+func (c *ZimaOSClient) api(baseURL string) (*apiProfile, error) {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+
+	if c.profile != nil && (c.profileFixed || c.profileURL == baseURL) {
+		return c.profile, nil
+	}
+	p, err := c.detectProfile(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	c.profile = p
+	c.profileURL = baseURL
+	return p, nil
+}
+
+// detectProfile 依次请求各版本的列目录接口，以第一个存在的接口确定版本
+// 探测请求不带 token，接口存在时服务端返回 401 等非 404 状态
+func (c *ZimaOSClient) detectProfile(baseURL string) (*apiProfile, error) {
+	var lastErr error
+	for _, name := range probeOrder {
+		p := apiProfiles[name]
+		ok, err := c.apiRouteExists(baseURL + p.List + "?" + url.Values{"path": {StorageRoot}}.Encode())
+		if ok {
+			return p, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("连接服务端失败: %w", lastErr)
+	}
+	return nil, fmt.Errorf("不支持的服务端: 未找到 ZimaOS (/v2_1/files) 或 CasaOS (/v1/folder) 文件接口，请确认地址指向 ZimaOS / CasaOS")
+}
+
+// apiRouteExists 请求接口并判断路由是否存在
+// 返回 404、网页 (前端页面兜底路由) 或非 JSON 内容 (其他服务) 时视为不存在
+func (c *ZimaOSClient) apiRouteExists(rawURL string) (bool, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.EqualFold(mediaType, "text/html") {
+		return false, nil
+	}
+	body = bytes.TrimSpace(body)
+	return len(body) > 0 && (body[0] == '{' || body[0] == '[') && json.Valid(body), nil
+}
+
+// route 返回当前版本中某操作的接口路径，不支持时返回错误
+func (c *ZimaOSClient) route(baseURL, action string, pick func(p *apiProfile) string) (string, error) {
+	p, err := c.api(baseURL)
+	if err != nil {
+		return "", err
+	}
+	apiPath := pick(p)
+	if apiPath == "" {
+		return "", p.unsupported(action)
+	}
+	return apiPath, nil
+}
+
+// unsupported 返回当前版本不支持某操作的错误
+func (p *apiProfile) unsupported(action string) error {
+	return fmt.Errorf("%s 不支持%s", p.Label, action)
+}
@@ -2,18 +2,110 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"ftoz/internal/model"
 )
 
+// StorageRoot ZimaOS 存储挂载根目录
+const StorageRoot = "/media"
+
+// listPageSize 列目录时每页条数
+const listPageSize = 500
+
+// RemoteFile ZimaOS 上的文件或目录
+type RemoteFile struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	IsDir    bool   `json:"is_dir"`
+	Modified int64  `json:"modified"`
+}
+
+// UnmarshalJSON 解析文件信息，modified 兼容时间戳 (ZimaOS) 和 RFC 3339 时间字符串 (CasaOS)
+func (f *RemoteFile) UnmarshalJSON(data []byte) error {
+	type plain RemoteFile
+	var raw struct {
+		plain
+		Modified json.RawMessage `json:"modified"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = RemoteFile(raw.plain)
+
+	var ts int64
+	var s string
+	switch {
+	case len(raw.Modified) == 0:
+	case json.Unmarshal(raw.Modified, &ts) == nil:
+		f.Modified = ts
+	case json.Unmarshal(raw.Modified, &s) == nil:
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil && t.Unix() > 0 {
+			f.Modified = t.Unix()
+		}
+	}
+	return nil
+}
+
+// ModTime 返回修改时间，兼容秒和毫秒两种时间戳
+func (f RemoteFile) ModTime() time.Time {
+	if f.Modified > 1e12 {
+		return time.UnixMilli(f.Modified)
+	}
+	return time.Unix(f.Modified, 0)
+}
+
+// remoteFolder getFolderInfo 返回的目录信息
+type remoteFolder struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Free     int64  `json:"free"`
+	ReadOnly bool   `json:"read_only"`
+}
+
 // ZimaOSClient ZimaOS API 客户端
+// 通过 NewZimaOSDestination 创建时同时作为迁移目标端 (Destination) 使用
+// 各接口的路径由服务端 API 版本决定 (见 apiProfile)，首次请求时探测
 type ZimaOSClient struct {
-	client *http.Client
+	client   *http.Client // 接口调用，带总超时
+	transfer *http.Client // 上传下载文件，不设总超时
+	session  *zimaSession
+
+	profileMu    sync.Mutex
+	profile      *apiProfile
+	profileURL   string // 探测版本时的服务端地址
+	profileFixed bool   // 版本由 SetAPIProfile 指定，不再探测
+}
+
+// zimaSession 作为迁移目标端时的连接信息
+type zimaSession struct {
+	baseURL  string
+	username string
+	password string
+	storage  string
+	root     string // 目标根目录，如 /media/ZimaOS-HD
+	token    string
+
+	mkdirUnsupported bool // 服务端不支持创建目录接口时由上传接口自动创建
+
+	dirTimeChecked     bool // 已核对过目录修改时间是否生效
+	dirTimeUnsupported bool
+
+	copyStaging string // 复制改名使用的临时目录，已创建时非空
 }
 
 // NewZimaOSClient 创建 ZimaOS 客户端
@@ -22,7 +114,32 @@ func NewZimaOSClient() *ZimaOSClient {
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		transfer: &http.Client{},
+	}
+}
+
+// SetTransport 设置接口调用和文件传输共用的传输层 (连接池、TLS、代理等)，未设置时使用默认传输层
+func (c *ZimaOSClient) SetTransport(t http.RoundTripper) {
+	c.client.Transport = t
+	c.transfer.Transport = t
+}
+
+// NewZimaOSDestination 创建以 /media/<storage> 为根目录的 ZimaOS 目标端
+func NewZimaOSDestination(baseURL, username, password, storage string) *ZimaOSClient {
+	c := NewZimaOSClient()
+	storage = strings.Trim(storage, "/")
+	root := StorageRoot
+	if storage != "" {
+		root = StorageRoot + "/" + storage
+	}
+	c.session = &zimaSession{
+		baseURL:  baseURL,
+		username: username,
+		password: password,
+		storage:  storage,
+		root:     root,
 	}
+	return c
 }
 
 // Login 登录 ZimaOS 获取 token
@@ -33,8 +150,12 @@ func (c *ZimaOSClient) Login(baseURL, username, password string) (string, error)
 	}
 	body, _ := json.Marshal(payload)
 
+	apiPath, err := c.route(baseURL, "登录", func(p *apiProfile) string { return p.Login })
+	if err != nil {
+		return "", err
+	}
 	resp, err := c.client.Post(
-		baseURL+"/v1/users/login",
+		baseURL+apiPath,
 		"application/json",
 		bytes.NewReader(body),
 	)
@@ -65,7 +186,11 @@ func (c *ZimaOSClient) CreateDir(baseURL, token, dirPath string) error {
 	payload := map[string]string{"path": dirPath}
 	body, _ := json.Marshal(payload)
 
-	req, _ := http.NewRequest("POST", baseURL+"/v2_1/files/folder", bytes.NewReader(body))
+	apiPath, err := c.route(baseURL, "创建目录", func(p *apiProfile) string { return p.Mkdir })
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequest("POST", baseURL+apiPath, bytes.NewReader(body))
 	req.Header.Set("Authorization", token)
 	req.Header.Set("Content-Type", "application/json")
 
@@ -93,6 +218,32 @@ func (c *ZimaOSClient) CreateDir(baseURL, token, dirPath string) error {
 	return nil
 }
 
+// DeleteFiles 永久删除远程文件或目录
+func (c *ZimaOSClient) DeleteFiles(baseURL, token string, paths []string) error {
+	apiPath, err := c.route(baseURL, "删除文件", func(p *apiProfile) string { return p.Delete })
+	if err != nil {
+		return err
+	}
+	return c.sendJSON(baseURL, token, "DELETE", apiPath, paths, "删除文件", nil)
+}
+
+// RenameFile 重命名远程文件或目录
+func (c *ZimaOSClient) RenameFile(baseURL, token, oldPath, newPath string) error {
+	p, err := c.api(baseURL)
+	if err != nil {
+		return err
+	}
+	switch {
+	case p.Rename == "":
+		return p.unsupported("重命名")
+	case p.Name == APIProfileCasaOS:
+		payload := map[string]string{"old_path": oldPath, "new_path": newPath}
+		return c.sendJSON(baseURL, token, "PUT", p.Rename, payload, "重命名", nil)
+	}
+	apiPath := p.Rename + "?" + url.Values{"path": {oldPath}}.Encode()
+	return c.sendJSON(baseURL, token, "PUT", apiPath, map[string]string{"new_path": newPath}, "重命名", nil)
+}
+
 // UploadFile 上传文件到 ZimaOS
 func (c *ZimaOSClient) UploadFile(baseURL, token, remoteDir, filename, localPath string) error {
 	stat, err := os.Stat(localPath)
@@ -106,37 +257,60 @@ func (c *ZimaOSClient) UploadFile(baseURL, token, remoteDir, filename, localPath
 	}
 	defer file.Close()
 
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
+	p, err := c.api(baseURL)
+	if err != nil {
+		return err
+	}
+	if p.Upload == "" {
+		return p.unsupported("上传文件")
+	}
+
+	// 表单字段和文件头先写入缓冲区，文件内容在发送时直接从文件读取，
+	// 避免并发上传大文件时整个文件留在内存中
+	var head bytes.Buffer
+	writer := multipart.NewWriter(&head)
 
 	// 添加 path 字段
 	writer.WriteField("path", remoteDir)
 
-	// 添加 modTime 字段
-	modTime := stat.ModTime().Unix()
-	writer.WriteField("modTime", fmt.Sprintf("%d", modTime))
+	if p.Name == APIProfileCasaOS {
+		// CasaOS 按分片上传接口处理，整个文件作为唯一的分片
+		writer.WriteField("relativePath", filename)
+		writer.WriteField("filename", filename)
+		writer.WriteField("chunkNumber", "1")
+		writer.WriteField("totalChunks", "1")
+		writer.WriteField("totalSize", strconv.FormatInt(stat.Size(), 10))
+	} else {
+		// 添加 modTime 字段
+		modTime := stat.ModTime().Unix()
+		writer.WriteField("modTime", fmt.Sprintf("%d", modTime))
+	}
 
 	// 添加文件
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
+	if _, err := writer.CreateFormFile("file", filename); err != nil {
 		return fmt.Errorf("创建表单文件失败: %w", err)
 	}
+	headLen := head.Len()
+	writer.Close()
+	trailer := append([]byte(nil), head.Bytes()[headLen:]...)
+	head.Truncate(headLen)
 
-	if _, err := io.Copy(part, file); err != nil {
-		return fmt.Errorf("写入文件内容失败: %w", err)
-	}
+	// 按上传前的文件大小发送，文件在上传过程中变大时只发送原大小，变小时请求失败
+	size := stat.Size()
+	body := io.MultiReader(&head, io.LimitReader(file, size), bytes.NewReader(trailer))
 
-	writer.Close()
+	// 上传不设置超时，避免大文件上传失败；连接停滞时由停滞检测取消请求
+	watch := watchStall(context.Background())
+	defer watch.stop()
 
-	req, _ := http.NewRequest("POST", baseURL+"/v2_1/files/file/uploadV2", &buf)
+	req, _ := http.NewRequestWithContext(watch.context(), "POST", baseURL+p.Upload, limitUpload(watch.reader(body)))
+	req.ContentLength = int64(headLen) + size + int64(len(trailer))
 	req.Header.Set("Authorization", token)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	// 上传不设置超时，避免大文件上传失败
-	uploadClient := &http.Client{}
-	resp, err := uploadClient.Do(req)
+	resp, err := c.transfer.Do(req)
 	if err != nil {
-		return fmt.Errorf("上传请求失败: %w", err)
+		return fmt.Errorf("上传请求失败: %w", watch.err(err))
 	}
 	defer resp.Body.Close()
 
@@ -146,6 +320,222 @@ func (c *ZimaOSClient) UploadFile(baseURL, token, remoteDir, filename, localPath
 	return c.assertResponse(resp.StatusCode, result, "上传")
 }
 
+// DownloadFile 下载远程文件并写入 w，返回写入的字节数
+func (c *ZimaOSClient) DownloadFile(baseURL, token, remotePath string, w io.Writer) (int64, error) {
+	query := url.Values{}
+	query.Set("path", remotePath)
+
+	apiPath, err := c.route(baseURL, "下载文件", func(p *apiProfile) string { return p.Download })
+	if err != nil {
+		return 0, err
+	}
+	req, _ := http.NewRequest("GET", baseURL+apiPath+"?"+query.Encode(), nil)
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Accept", "application/octet-stream")
+
+	// 下载不设置超时，避免大文件下载失败
+	resp, err := c.transfer.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("下载请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		if err := c.assertResponse(resp.StatusCode, result, "下载"); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("下载失败(%d)", resp.StatusCode)
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("下载文件内容失败: %w", err)
+	}
+	return n, nil
+}
+
+// ListFiles 列出远程目录下的文件和子目录 (自动翻页)
+func (c *ZimaOSClient) ListFiles(baseURL, token, dirPath string) ([]RemoteFile, error) {
+	apiPath, err := c.route(baseURL, "读取目录", func(p *apiProfile) string { return p.List })
+	if err != nil {
+		return nil, err
+	}
+
+	var files []RemoteFile
+	for index := 1; ; index++ {
+		query := url.Values{}
+		query.Set("path", dirPath)
+		query.Set("index", strconv.Itoa(index))
+		query.Set("size", strconv.Itoa(listPageSize))
+
+		var page struct {
+			Content []RemoteFile `json:"content"`
+			Total   int          `json:"total"`
+		}
+		if err := c.getJSON(baseURL, token, apiPath, query, "读取目录", &page); err != nil {
+			return nil, err
+		}
+
+		for _, f := range page.Content {
+			if f.Path == "" {
+				f.Path = path.Join(dirPath, f.Name)
+			}
+			files = append(files, f)
+		}
+
+		if len(page.Content) < listPageSize || len(files) >= page.Total {
+			break
+		}
+	}
+	return files, nil
+}
+
+// GetFolderInfo 获取远程目录信息，withSize 为 true 时由服务端统计目录大小
+func (c *ZimaOSClient) GetFolderInfo(baseURL, token, dirPath string, withSize bool) (*remoteFolder, error) {
+	apiPath, err := c.route(baseURL, "查询目录信息", func(p *apiProfile) string { return p.FolderInfo })
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("path", dirPath)
+	if withSize {
+		query.Set("size", "true")
+	}
+
+	var folders []remoteFolder
+	if err := c.getJSON(baseURL, token, apiPath, query, "获取目录信息", &folders); err != nil {
+		return nil, err
+	}
+	if len(folders) == 0 {
+		return nil, fmt.Errorf("目录不存在: %s", dirPath)
+	}
+	return &folders[0], nil
+}
+
+// ListStorages 列出 ZimaOS 上可用的存储及其顶层目录
+func (c *ZimaOSClient) ListStorages(baseURL, token string, withDetail bool) ([]model.StorageInfo, error) {
+	files, err := c.ListFiles(baseURL, token, StorageRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	storages := []model.StorageInfo{}
+	for _, f := range files {
+		if !f.IsDir {
+			continue
+		}
+		storage := model.StorageInfo{
+			Name:    f.Name,
+			Path:    StorageRoot + "/" + f.Name,
+			Folders: []string{},
+		}
+
+		if withDetail {
+			if info, err := c.GetFolderInfo(baseURL, token, storage.Path, true); err == nil {
+				storage.Free = info.Free
+				storage.Used = info.Size
+				storage.Total = info.Size + info.Free
+				storage.ReadOnly = info.ReadOnly
+			}
+			if children, err := c.ListFiles(baseURL, token, storage.Path); err == nil {
+				for _, child := range children {
+					if child.IsDir {
+						storage.Folders = append(storage.Folders, child.Name)
+					}
+				}
+			}
+		}
+
+		storages = append(storages, storage)
+	}
+	return storages, nil
+}
+
+// ValidateStorage 校验存储名称是否存在，storage 为空表示上传到存储根目录
+func (c *ZimaOSClient) ValidateStorage(baseURL, token, storage string) error {
+	if storage == "" {
+		return nil
+	}
+	name := strings.SplitN(storage, "/", 2)[0]
+
+	storages, err := c.ListStorages(baseURL, token, false)
+	if err != nil {
+		return fmt.Errorf("获取存储列表失败: %w", err)
+	}
+
+	names := make([]string, 0, len(storages))
+	for _, s := range storages {
+		if s.Name == name {
+			return nil
+		}
+		names = append(names, s.Name)
+	}
+	return fmt.Errorf("存储 %s 不存在，可用存储: %s", name, strings.Join(names, ", "))
+}
+
+// getJSON 发送 GET 请求并将响应的 data 字段解析到 out
+func (c *ZimaOSClient) getJSON(baseURL, token, apiPath string, query url.Values, action string, out interface{}) error {
+	req, _ := http.NewRequest("GET", baseURL+apiPath+"?"+query.Encode(), nil)
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s请求失败: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	return c.decodeData(resp, action, out)
+}
+
+// decodeData 解析响应，兼容 {data: ...} 包装和直接返回数据两种格式
+func (c *ZimaOSClient) decodeData(resp *http.Response, action string, out interface{}) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取%s响应失败: %w", action, err)
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := c.assertResponse(resp.StatusCode, nil, action); err != nil {
+			return err
+		}
+		if out == nil || len(trimmed) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(trimmed, out); err != nil {
+			return fmt.Errorf("解析%s响应失败: %w", action, err)
+		}
+		return nil
+	}
+
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &result); err != nil {
+		return fmt.Errorf("解析%s响应失败: %w", action, err)
+	}
+
+	var generic map[string]interface{}
+	json.Unmarshal(trimmed, &generic)
+	if err := c.assertResponse(resp.StatusCode, generic, action); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	data, ok := result["data"]
+	if !ok {
+		data = trimmed
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析%s响应失败: %w", action, err)
+	}
+	return nil
+}
+
 // extractToken 从响应中提取 token
 func (c *ZimaOSClient) extractToken(data map[string]interface{}) string {
 	// 尝试多种路径提取 token: data.token.access_token / data.token / token
@@ -214,3 +604,200 @@ func (c *ZimaOSClient) isDirExistsError(msg string) bool {
 	}
 	return false
 }
+
+// Authenticate 登录并校验目标存储，避免上传到不存在的 /media 子目录
+func (c *ZimaOSClient) Authenticate() error {
+	token, err := c.Login(c.session.baseURL, c.session.username, c.session.password)
+	if err != nil {
+		return err
+	}
+	if err := c.ValidateStorage(c.session.baseURL, token, c.session.storage); err != nil {
+		return err
+	}
+	c.session.token = token
+	return nil
+}
+
+// Mkdir 在目标根目录下创建目录
+func (c *ZimaOSClient) Mkdir(relDir string) error {
+	if c.session.mkdirUnsupported {
+		return nil
+	}
+	err := c.CreateDir(c.session.baseURL, c.session.token, c.remotePath(relDir))
+	if err != nil && strings.Contains(err.Error(), "404") {
+		c.session.mkdirUnsupported = true
+		return nil
+	}
+	return err
+}
+
+// SetDirTime 通过目录更新接口 (与重命名相同，目标路径不变) 设置目录修改时间
+// 不支持该字段的版本会忽略 mod_time，因此第一次设置后列出父目录核对，未生效时不再尝试
+func (c *ZimaOSClient) SetDirTime(relDir string, mtime time.Time) error {
+	if c.session.dirTimeUnsupported {
+		return ErrDirTimeUnsupported
+	}
+	p, err := c.api(c.session.baseURL)
+	if err != nil {
+		return err
+	}
+	if p.DirModTime == "" {
+		c.session.dirTimeUnsupported = true
+		return ErrDirTimeUnsupported
+	}
+
+	dir := c.remotePath(relDir)
+	apiPath := p.DirModTime + "?" + url.Values{"path": {dir}}.Encode()
+	payload := map[string]interface{}{"new_path": dir, "mod_time": mtime.Unix()}
+	err = c.sendJSON(c.session.baseURL, c.session.token, "PUT", apiPath, payload, "设置目录修改时间", nil)
+	if c.session.dirTimeChecked {
+		return err
+	}
+
+	c.session.dirTimeChecked = true
+	if err == nil {
+		var entry *DestEntry
+		if entry, err = c.Stat(relDir); err == nil && entry.ModTime.Unix() != mtime.Unix() {
+			err = ErrDirTimeUnsupported
+		}
+	}
+	if err != nil {
+		c.session.dirTimeUnsupported = true
+		return ErrDirTimeUnsupported
+	}
+	return nil
+}
+
+// PutFile 上传文件到目标根目录下的 relPath
+func (c *ZimaOSClient) PutFile(relPath, localPath string) error {
+	dir, name := path.Split(relPath)
+	return c.UploadFile(c.session.baseURL, c.session.token, c.remotePath(dir), name, localPath)
+}
+
+// Stat 查询目标根目录下的文件或目录
+func (c *ZimaOSClient) Stat(relPath string) (*DestEntry, error) {
+	if relPath == "" {
+		return &DestEntry{IsDir: true}, nil
+	}
+	return statByList(c, relPath)
+}
+
+// List 列出目标根目录下的目录
+func (c *ZimaOSClient) List(relDir string) ([]DestEntry, error) {
+	files, err := c.ListFiles(c.session.baseURL, c.session.token, c.remotePath(relDir))
+	if err != nil {
+		return nil, err
+	}
+	p, err := c.api(c.session.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]DestEntry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, DestEntry{Name: f.Name, IsDir: f.IsDir, Size: f.Size, ModTime: f.ModTime(), Uploaded: !p.UploadModTime})
+	}
+	return entries, nil
+}
+
+// Free 返回目标存储的可用空间
+func (c *ZimaOSClient) Free() (int64, error) {
+	info, err := c.GetFolderInfo(c.session.baseURL, c.session.token, c.session.root, false)
+	if err != nil {
+		return 0, err
+	}
+	return info.Free, nil
+}
+
+// Extract 通过服务端解压任务将归档解压到目标目录，等待任务结束
+func (c *ZimaOSClient) Extract(relArchive, relDir string) error {
+	task, err := c.DecompressFile(c.session.baseURL, c.session.token, c.remotePath(relArchive), c.remotePath(relDir), UserSelectOverwrite)
+	if err != nil {
+		return err
+	}
+	return c.waitTask(task, "解压")
+}
+
+// Copy 通过服务端复制任务将 relSrc 复制为 relDst
+// 复制任务保留原文件名，文件名不同时先复制到临时目录再改名；临时目录在本次任务中复用，由 CleanupCopies 删除
+func (c *ZimaOSClient) Copy(relSrc, relDst string) error {
+	src, dst := c.remotePath(relSrc), c.remotePath(relDst)
+	dstDir, name := path.Dir(dst), path.Base(dst)
+	if path.Base(src) == name {
+		task, err := c.CopyFiles(c.session.baseURL, c.session.token, []string{src}, dstDir, UserSelectOverwrite)
+		if err != nil {
+			return err
+		}
+		return c.waitTask(task, "复制")
+	}
+
+	staging, err := c.copyStagingDir()
+	if err != nil {
+		return err
+	}
+	task, err := c.CopyFiles(c.session.baseURL, c.session.token, []string{src}, staging, UserSelectOverwrite)
+	if err != nil {
+		return err
+	}
+	if err := c.waitTask(task, "复制"); err != nil {
+		return err
+	}
+	// 改名接口不覆盖已有文件
+	c.DeleteFiles(c.session.baseURL, c.session.token, []string{dst})
+	return c.RenameFile(c.session.baseURL, c.session.token, staging+"/"+path.Base(src), dst)
+}
+
+// copyStagingDir 返回复制改名使用的临时目录，首次使用时创建
+func (c *ZimaOSClient) copyStagingDir() (string, error) {
+	if c.session.copyStaging == "" {
+		staging := c.remotePath(fmt.Sprintf(".ftoz-copy-%d", os.Getpid()))
+		if err := c.CreateDir(c.session.baseURL, c.session.token, staging); err != nil {
+			return "", err
+		}
+		c.session.copyStaging = staging
+	}
+	return c.session.copyStaging, nil
+}
+
+// CleanupCopies 删除复制改名使用的临时目录，未创建时不做任何操作
+func (c *ZimaOSClient) CleanupCopies() error {
+	if c.session.copyStaging == "" {
+		return nil
+	}
+	if err := c.DeleteFiles(c.session.baseURL, c.session.token, []string{c.session.copyStaging}); err != nil {
+		return err
+	}
+	c.session.copyStaging = ""
+	return nil
+}
+
+// waitTask 等待服务端任务结束，失败或取消时返回错误
+func (c *ZimaOSClient) waitTask(task *ZimaTask, action string) error {
+	task, err := c.WaitTask(c.session.baseURL, c.session.token, task, taskPollInterval)
+	if err != nil {
+		return err
+	}
+	switch task.Status {
+	case ZimaTaskFailed:
+		if task.ErrMsg != "" {
+			return fmt.Errorf("%s失败: %s", action, task.ErrMsg)
+		}
+		return fmt.Errorf("%s失败", action)
+	case ZimaTaskCancelled:
+		return fmt.Errorf("%s任务已取消", action)
+	}
+	return nil
+}
+
+// Remove 删除目标根目录下的文件
+func (c *ZimaOSClient) Remove(relPath string) error {
+	return c.DeleteFiles(c.session.baseURL, c.session.token, []string{c.remotePath(relPath)})
+}
+
+// remotePath 将相对路径转换为远程完整路径
+func (c *ZimaOSClient) remotePath(relPath string) string {
+	relPath = strings.Trim(relPath, "/")
+	if relPath == "" {
+		return c.session.root
+	}
+	return c.session.root + "/" + relPath
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// 服务端任务状态
+	ZimaTaskPending     = "pending"
+	ZimaTaskCalculating = "calculating"
+	ZimaTaskRunning     = "running"
+	ZimaTaskCancelled   = "cancelled"
+	ZimaTaskSuccess     = "success"
+	ZimaTaskFailed      = "failed"
+
+	// 目标已存在时的处理方式 (user_select)
+	UserSelectOverwrite = "overwrite"
+	UserSelectRename    = "rename"
+	UserSelectSkip      = "skip"
+
+	// taskPollInterval 等待解压、复制等服务端任务时的查询间隔
+	taskPollInterval = time.Second
+)
+
+// ZimaConnect ZimaOS 上的远程连接 (挂载)
+type ZimaConnect struct {
+	ID         int    `json:"id"`
+	Host       string `json:"host"`
+	Port       string `json:"port"`
+	Username   string `json:"username"`
+	MountPoint string `json:"mount_point"`
+}
+
+// ZimaTask ZimaOS 服务端文件任务 (复制、导入、解压等)
+type ZimaTask struct {
+	ID            int64  `json:"id"`
+	Type          string `json:"type"`
+	Status        string `json:"status"`
+	ErrMsg        string `json:"err_msg"`
+	Progress      int    `json:"progress"`
+	TotalItem     int64  `json:"total_item"`
+	ProcessedItem int64  `json:"processed_item"`
+	TotalSize     int64  `json:"total_size"`
+	ProcessedSize int64  `json:"processed_size"`
+	Speed         int64  `json:"speed"`
+}
+
+// Finished 任务是否已结束
+func (t *ZimaTask) Finished() bool {
+	return t.Status == ZimaTaskSuccess || t.Status == ZimaTaskFailed || t.Status == ZimaTaskCancelled
+}
+
+// CreateConnect 在 ZimaOS 上创建远程连接，返回挂载信息
+func (c *ZimaOSClient) CreateConnect(baseURL, token, host, port, username, password string) (*ZimaConnect, error) {
+	payload := map[string]string{
+		"host":     host,
+		"port":     port,
+		"username": username,
+		"password": password,
+	}
+	apiPath, err := c.route(baseURL, "远程连接", func(p *apiProfile) string { return p.Connect })
+	if err != nil {
+		return nil, err
+	}
+	if err := c.sendJSON(baseURL, token, "POST", apiPath, payload, "创建远程连接", nil); err != nil {
+		return nil, err
+	}
+
+	// 创建接口不保证返回挂载点，从连接列表中查找
+	connects, err := c.ListConnects(baseURL, token)
+	if err != nil {
+		return nil, err
+	}
+	for i := range connects {
+		conn := &connects[i]
+		if strings.EqualFold(conn.Host, host) && conn.Port == port && conn.Username == username {
+			if conn.MountPoint == "" {
+				return nil, fmt.Errorf("远程连接已创建但未返回挂载点")
+			}
+			return conn, nil
+		}
+	}
+	return nil, fmt.Errorf("远程连接已创建但未出现在连接列表中")
+}
+
+// ListConnects 列出远程连接
+func (c *ZimaOSClient) ListConnects(baseURL, token string) ([]ZimaConnect, error) {
+	var connects []ZimaConnect
+	apiPath, err := c.route(baseURL, "远程连接", func(p *apiProfile) string { return p.Connect })
+	if err != nil {
+		return nil, err
+	}
+	if err := c.getJSON(baseURL, token, apiPath, url.Values{}, "获取远程连接", &connects); err != nil {
+		return nil, err
+	}
+	return connects, nil
+}
+
+// DeleteConnect 删除远程连接 (卸载)
+func (c *ZimaOSClient) DeleteConnect(baseURL, token string, id int) error {
+	apiPath, err := c.route(baseURL, "远程连接", func(p *apiProfile) string { return p.Connect })
+	if err != nil {
+		return err
+	}
+	return c.sendJSON(baseURL, token, "DELETE", apiPath+"/"+strconv.Itoa(id), nil, "删除远程连接", nil)
+}
+
+// ImportFiles 创建服务端导入任务，将 src 复制到 ZimaOS 的 dst 目录下
+func (c *ZimaOSClient) ImportFiles(baseURL, token string, src []string, dst, userSelect string) (*ZimaTask, error) {
+	apiPath, err := c.route(baseURL, "服务端导入", func(p *apiProfile) string { return p.TaskImport })
+	if err != nil {
+		return nil, err
+	}
+	return c.createTask(baseURL, token, apiPath, src, dst, userSelect, "创建导入任务")
+}
+
+// DecompressFile 创建服务端解压任务，将归档 archive 解压到 dst 目录
+func (c *ZimaOSClient) DecompressFile(baseURL, token, archive, dst, userSelect string) (*ZimaTask, error) {
+	apiPath, err := c.route(baseURL, "服务端解压", func(p *apiProfile) string { return p.TaskDecompress })
+	if err != nil {
+		return nil, err
+	}
+	return c.createTask(baseURL, token, apiPath, []string{archive}, dst, userSelect, "创建解压任务")
+}
+
+// CopyFiles 创建服务端复制任务，将 src 复制到 dst 目录下
+func (c *ZimaOSClient) CopyFiles(baseURL, token string, src []string, dst, userSelect string) (*ZimaTask, error) {
+	apiPath, err := c.route(baseURL, "服务端复制", func(p *apiProfile) string { return p.TaskCopy })
+	if err != nil {
+		return nil, err
+	}
+	return c.createTask(baseURL, token, apiPath, src, dst, userSelect, "创建复制任务")
+}
+
+// WaitTask 按 interval 查询任务直到结束，返回最终状态
+func (c *ZimaOSClient) WaitTask(baseURL, token string, task *ZimaTask, interval time.Duration) (*ZimaTask, error) {
+	for !task.Finished() {
+		time.Sleep(interval)
+		latest, err := c.GetTask(baseURL, token, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		task = latest
+	}
+	return task, nil
+}
+
+// GetTask 通过任务列表查询任务状态
+func (c *ZimaOSClient) GetTask(baseURL, token string, id int64) (*ZimaTask, error) {
+	apiPath, err := c.route(baseURL, "服务端任务", func(p *apiProfile) string { return p.Tasks })
+	if err != nil {
+		return nil, err
+	}
+	var tasks []ZimaTask
+	if err := c.getJSON(baseURL, token, apiPath, url.Values{}, "获取任务列表", &tasks); err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		if tasks[i].ID == id {
+			return &tasks[i], nil
+		}
+	}
+	return nil, fmt.Errorf("任务 %d 不存在", id)
+}
+
+func (c *ZimaOSClient) createTask(baseURL, token, apiPath string, src []string, dst, userSelect, action string) (*ZimaTask, error) {
+	if userSelect == "" {
+		userSelect = UserSelectOverwrite
+	}
+	payload := map[string]interface{}{
+		"src":         src,
+		"dst":         dst,
+		"user_select": userSelect,
+	}
+	var task ZimaTask
+	if err := c.sendJSON(baseURL, token, "POST", apiPath, payload, action, &task); err != nil {
+		return nil, err
+	}
+	if task.ID == 0 {
+		return nil, fmt.Errorf("%s失败: 未返回任务 ID", action)
+	}
+	return &task, nil
+}
+
+// sendJSON 发送 JSON 请求体并将响应的 data 字段解析到 out
+func (c *ZimaOSClient) sendJSON(baseURL, token, method, apiPath string, payload interface{}, action string, out interface{}) error {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+
+	req, _ := http.NewRequest(method, baseURL+apiPath, bytes.NewReader(body))
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s请求失败: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	return c.decodeData(resp, action, out)
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"fmt"
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/encoding/japanese"
@@ -9,6 +10,7 @@ import (
 	"golang.org/x/text/encoding/traditionalchinese"
 	"golang.org/x/text/encoding/unicode"
 	"strings"
+	"unicode/utf8"
 )
 
 var encodingMap = map[string]encoding.Encoding{
@@ -57,3 +59,111 @@ func EncodeString(s string, encodingName string) ([]byte, error) {
 
 	return enc.NewEncoder().Bytes([]byte(s))
 }
+
+// DecodeString 将指定编码的字节转换为 UTF-8 字符串
+// 字节序列在该编码下无效时返回错误
+func DecodeString(b []byte, encodingName string) (string, error) {
+	enc := GetEncoding(encodingName)
+	if enc == nil {
+		return "", fmt.Errorf("不支持的编码: %s", encodingName)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", err
+	}
+	// 解码器遇到无效字节时输出替换字符而不报错
+	replacement := string(utf8.RuneError)
+	if strings.Contains(string(decoded), replacement) && !strings.Contains(string(b), replacement) {
+		return "", fmt.Errorf("不是有效的 %s 编码", encodingName)
+	}
+	return string(decoded), nil
+}
+
+// DetectCharset 猜测非 UTF-8 文件名的编码 (GBK 或 Big5)，无法识别时返回空字符串
+// 按常用汉字所在的编码区间打分，得分相同时优先 GBK
+func DetectCharset(b []byte) string {
+	best, bestScore := "", -1
+	for _, c := range []struct {
+		name  string
+		score func([]byte) int
+	}{
+		{"gbk", scoreGBK},
+		{"big5", scoreBig5},
+	} {
+		score := c.score(b)
+		if score <= bestScore {
+			continue
+		}
+		if _, err := DecodeString(b, c.name); err != nil {
+			continue
+		}
+		best, bestScore = c.name, score
+	}
+	return best
+}
+
+// scoreGBK GB2312 一级汉字区 (B0-D7) 计 2 分，二级汉字区 (D8-F7) 计 1 分，无效时返回 -1
+func scoreGBK(b []byte) int {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		if b[i] < 0x80 {
+			continue
+		}
+		if i+1 >= len(b) || b[i] < 0x81 || b[i] == 0xFF || b[i+1] < 0x40 || b[i+1] == 0x7F || b[i+1] == 0xFF {
+			return -1
+		}
+		lead, trail := b[i], b[i+1]
+		switch {
+		case lead >= 0xB0 && lead <= 0xD7 && trail >= 0xA1:
+			score += 2
+		case lead >= 0xD8 && lead <= 0xF7 && trail >= 0xA1:
+			score++
+		}
+		i++
+	}
+	return score
+}
+
+// scoreBig5 Big5 常用字区 (A4-C6) 计 2 分，次常用字区 (C9-F9) 计 1 分，无效时返回 -1
+func scoreBig5(b []byte) int {
+	score := 0
+	for i := 0; i < len(b); i++ {
+		if b[i] < 0x80 {
+			continue
+		}
+		if i+1 >= len(b) || b[i] < 0xA1 || b[i] > 0xF9 {
+			return -1
+		}
+		lead, trail := b[i], b[i+1]
+		if !(trail >= 0x40 && trail <= 0x7E) && !(trail >= 0xA1 && trail <= 0xFE) {
+			return -1
+		}
+		switch {
+		case lead >= 0xA4 && lead <= 0xC6:
+			score += 2
+		case lead >= 0xC9:
+			score++
+		}
+		i++
+	}
+	return score
+}
+
+// EscapeInvalidUTF8 将字符串中无效的 UTF-8 字节转义为 \xNN，便于在 JSON 中展示原始文件名
+func EscapeInvalidUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&sb, "\\x%02X", s[i])
+		} else {
+			sb.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return sb.String()
+}